@@ -0,0 +1,132 @@
+package buffer
+
+var (
+	closingBrackets = map[rune]rune{'(': ')', '[': ']', '{': '}'}
+	openingBrackets = map[rune]rune{')': '(', ']': '[', '}': '{'}
+)
+
+func isBracket(r rune) bool {
+	_, opening := closingBrackets[r]
+	_, closing := openingBrackets[r]
+	return opening || closing
+}
+
+// MatchBracket will move the cursor to the bracket which matches the first
+// bracket at or after the cursor on the current line. Brackets within
+// strings are skipped, unless the bracket being matched is in a string.
+func (file *File) MatchBracket() (xPosition int, lines int, found bool) {
+	data := file.Current.Data
+	for i := file.runeOffset; i < len(data); i++ {
+		if !isBracket(data[i]) {
+			continue
+		}
+		target, ok := file.matchBracket(Position{Line: file.Current, Offset: i})
+		if !ok {
+			return file.spacingOffset, 0, false
+		}
+		xPosition, lines = file.moveTo(target)
+		return xPosition, lines, true
+	}
+	return file.spacingOffset, 0, false
+}
+
+func (file *File) matchBracket(from Position) (Position, bool) {
+	bracket := from.Line.Data[from.Offset]
+	match, forward := closingBrackets[bracket], true
+	if opening, ok := openingBrackets[bracket]; ok {
+		match, forward = opening, false
+	}
+	spansLine := from.Line
+	spans := stringSpans(from.Line.Data)
+	skipStrings := !inString(spans, from.Offset)
+	step := stepForward
+	if !forward {
+		step = stepBackward
+	}
+	depth := 0
+	position := from
+	for {
+		if !step(&position) {
+			return from, false
+		}
+		if position.Offset >= len(position.Line.Data) {
+			continue
+		}
+		if position.Line != spansLine {
+			spansLine = position.Line
+			spans = stringSpans(position.Line.Data)
+		}
+		if skipStrings && inString(spans, position.Offset) {
+			continue
+		}
+		switch position.Line.Data[position.Offset] {
+		case bracket:
+			depth++
+		case match:
+			if depth == 0 {
+				return position, true
+			}
+			depth--
+		}
+	}
+}
+
+// stringSpans finds the string literals on a line, which are runs within
+// double quotes or backquotes, and character literals such as '(' or '\n'.
+func stringSpans(data []rune) []span {
+	spans := make([]span, 0)
+	for i := 0; i < len(data); i++ {
+		end := -1
+		switch data[i] {
+		case '"', '`':
+			end = closingQuote(data, i)
+		case '\'':
+			end = characterLiteralEnd(data, i)
+		}
+		if end >= 0 {
+			spans = append(spans, span{start: i, end: end + 1})
+			i = end
+		}
+	}
+	return spans
+}
+
+func closingQuote(data []rune, open int) int {
+	for i := open + 1; i < len(data); i++ {
+		if data[i] == '\\' && data[open] != '`' {
+			i++
+			continue
+		}
+		if data[i] == data[open] {
+			return i
+		}
+	}
+	return -1
+}
+
+// characterLiteralEnd finds the closing quote of a character literal, so
+// that an apostrophe in a comment is not mistaken for the start of one.
+func characterLiteralEnd(data []rune, open int) int {
+	const longestEscape = 10
+	if open+1 < len(data) && data[open+1] == '\\' {
+		for i := open + 3; i < len(data) && i <= open+longestEscape; i++ {
+			if data[i] == '\'' {
+				return i
+			}
+		}
+		return -1
+	}
+	if open+2 < len(data) && data[open+2] == '\'' {
+		return open + 2
+	}
+	return -1
+}
+
+func inString(spans []span, offset int) bool {
+	for _, s := range spans {
+		if offset >= s.start && offset < s.end {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package buffer
+
+import "testing"
+
+func TestMatchBracket(t *testing.T) {
+	file := fileWithText("if f(a, (b)) {\n\tx = \"}\"\n\ty = '{'\n}")
+	_, lines, found := file.MatchBracket()
+	if !found || lines != 0 || file.Cursor().Offset != 11 {
+		t.Errorf("bad match of parenthesis: %d", file.Cursor().Offset)
+	}
+	_, _, found = file.MatchBracket()
+	if !found || file.Cursor().Offset != 4 {
+		t.Errorf("bad match back: %d", file.Cursor().Offset)
+	}
+	file.SetCursor(Position{Line: file.First, Offset: 12}, false)
+	_, lines, found = file.MatchBracket()
+	if !found || lines != 3 || file.Current != file.last {
+		t.Error("brackets in strings should be skipped")
+	}
+	_, lines, found = file.MatchBracket()
+	if !found || lines != 3 || file.Cursor().Offset != 13 {
+		t.Error("should have matched back to the first line")
+	}
+}
+
+func TestMatchBracketInString(t *testing.T) {
+	file := fileWithText("s := \"(\" + \")\" // don't")
+	file.SetCursor(Position{Line: file.First, Offset: 6}, false)
+	_, _, found := file.MatchBracket()
+	if !found || file.Cursor().Offset != 12 {
+		t.Errorf("brackets within strings should match each other: %d", file.Cursor().Offset)
+	}
+	file.SetCursor(Position{Line: file.First, Offset: 14}, false)
+	if _, _, found = file.MatchBracket(); found {
+		t.Error("there is no bracket after the cursor")
+	}
+}
+
+func TestStringSpans(t *testing.T) {
+	spans := stringSpans([]rune(`a "b\"c" 'd' '\n' it's ` + "`e\\`"))
+	expected := []span{{start: 2, end: 8}, {start: 9, end: 12}, {start: 13, end: 17}, {start: 23, end: 27}}
+	if len(spans) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, spans)
+	}
+	for i := range spans {
+		if spans[i] != expected[i] {
+			t.Errorf("expected %v but got %v", expected[i], spans[i])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package buffer
+
+const maxChanges = 100
+
+// The change list holds where the file was last changed, oldest first.
+// Each mutator adds the position of its change, replacing the newest entry
+// when it is on the same line. Positions on lines which are removed move
+// to where the lines were, so that the list survives edits around it.
+type changeList struct {
+	positions []Position
+	index     int
+}
+
+func (file *File) recordChange(position Position) {
+	file.mutated = true
+	changes := &file.changes
+	if last := len(changes.positions) - 1; last >= 0 && changes.positions[last].Line == position.Line {
+		changes.positions[last] = position
+	} else {
+		changes.positions = append(changes.positions, position)
+		if len(changes.positions) > maxChanges {
+			changes.positions = changes.positions[len(changes.positions)-maxChanges:]
+		}
+	}
+	changes.index = len(changes.positions)
+}
+
+// moveChanges moves the positions which are on the lines from first to
+// last, inclusive, to the position they are being merged into.
+func (file *File) moveChanges(first, last *Line, to Position) {
+	removed := make(map[*Line]bool)
+	for line := first; line != nil; line = line.Next {
+		removed[line] = true
+		if line == last {
+			break
+		}
+	}
+	for i, position := range file.changes.positions {
+		if removed[position.Line] {
+			file.changes.positions[i] = to
+		}
+	}
+}
+
+// LastChange returns the position of the newest change, which is the "."
+// mark, and reports whether the file has been changed.
+func (file *File) LastChange() (Position, bool) {
+	positions := file.changes.positions
+	if len(positions) == 0 {
+		return Position{}, false
+	}
+	return boundPosition(positions[len(positions)-1]), true
+}
+
+// StepChange moves through the change list by the number of steps, which
+// go back to older changes when negative, and returns the position. It
+// reports false when there is no change in that direction.
+func (file *File) StepChange(steps int) (Position, bool) {
+	changes := &file.changes
+	if len(changes.positions) == 0 {
+		return Position{}, false
+	}
+	index := changes.index + steps
+	if steps < 0 && changes.index == 0 || steps > 0 && changes.index >= len(changes.positions)-1 {
+		return Position{}, false
+	}
+	if index < 0 {
+		index = 0
+	}
+	if index > len(changes.positions)-1 {
+		index = len(changes.positions) - 1
+	}
+	changes.index = index
+	return boundPosition(changes.positions[index]), true
+}
+
+// Changes returns every position in the change list, and the index of the
+// entry which was last stepped to, or the length when it has not been used.
+func (file *File) Changes() ([]Position, int) {
+	positions := make([]Position, len(file.changes.positions))
+	for i, position := range file.changes.positions {
+		positions[i] = boundPosition(position)
+	}
+	return positions, file.changes.index
+}
@@ -0,0 +1,83 @@
+package buffer
+
+import "testing"
+
+func TestChangeList(t *testing.T) {
+	file := unchangedFile("one\ntwo\nthree\nfour")
+	file.SetCursor(Position{Line: file.First.Next, Offset: 1}, false)
+	file.Remove()
+	file.SetCursor(Position{Line: file.last, Offset: 2}, false)
+	file.Remove()
+	file.Remove()
+	positions, index := file.Changes()
+	if len(positions) != 2 || index != 2 {
+		t.Fatalf("changes on the same line should be merged: %d", len(positions))
+	}
+	last, ok := file.LastChange()
+	if !ok || last.Line != file.last || last.Offset != 1 {
+		t.Error("last change should be the newest position")
+	}
+	position, ok := file.StepChange(-1)
+	if !ok || position.Line != file.last {
+		t.Error("first step back should go to the newest change")
+	}
+	position, ok = file.StepChange(-1)
+	if !ok || string(position.Line.Data) != "to" {
+		t.Error("second step back should go to the older change")
+	}
+	if _, ok = file.StepChange(-1); ok {
+		t.Error("should not step back past the oldest change")
+	}
+	if position, ok = file.StepChange(5); !ok || position.Line != file.last {
+		t.Error("stepping forward should stop at the newest change")
+	}
+	if _, ok = file.StepChange(1); ok {
+		t.Error("should not step forward past the newest change")
+	}
+}
+
+func TestChangeFollowsLine(t *testing.T) {
+	file := unchangedFile("one\ntwo\nthree")
+	file.SetCursor(Position{Line: file.last, Offset: 2}, false)
+	file.Remove()
+	file.JumpToTop()
+	file.InsertLines([]rune("new\nnewer\n"), false)
+	file.DeleteLines(file.First, file.First)
+	positions, _ := file.Changes()
+	if len(positions) != 2 || string(positions[0].Line.Data) != "thee" || positions[0].Offset != 2 {
+		t.Errorf("change should stay on its line: %d", len(positions))
+	}
+	if file.LineNumber(positions[0].Line) != 4 {
+		t.Errorf("change should have moved down: %d", file.LineNumber(positions[0].Line))
+	}
+}
+
+func TestChangeMovedFromRemovedLine(t *testing.T) {
+	file := unchangedFile("one\ntwo\nthree")
+	file.SetCursor(Position{Line: file.First.Next, Offset: 1}, false)
+	file.Remove()
+	file.SetCursor(Position{Line: file.First, Offset: 0}, false)
+	file.Remove()
+	file.DeleteLines(file.First.Next, file.First.Next)
+	positions, _ := file.Changes()
+	for _, position := range positions {
+		if !file.Contains(position.Line) {
+			t.Fatal("change should not be left on a removed line")
+		}
+	}
+	if positions[0].Line != file.last {
+		t.Error("change on a removed line should move to the line after it")
+	}
+	file.SetCursor(Position{Line: file.last, Offset: 0}, false)
+	file.Backspace()
+	last, _ := file.LastChange()
+	if last.Line != file.First || last.Offset != 2 || file.Lines != 1 {
+		t.Error("joining lines should move changes to the joined line")
+	}
+}
+
+func unchangedFile(text string) *File {
+	file := fileWithText(text)
+	file.changes = changeList{}
+	return file
+}
@@ -0,0 +1,84 @@
+package buffer
+
+import (
+	"strconv"
+	"unicode"
+)
+
+var charsets = map[string]struct {
+	encoding string
+	bomb     bool
+}{
+	"latin1":    {"latin1", false},
+	"utf-8":     {"utf-8", false},
+	"utf-8-bom": {"utf-8", true},
+	"utf-16be":  {"utf-16", false},
+	"utf-16le":  {"utf-16le", false},
+}
+
+var lineEndings = map[string]string{
+	"lf":   "unix",
+	"crlf": "dos",
+	"cr":   "mac",
+}
+
+// applyEditorConfig sets the buffer-local options from the properties
+// of the EditorConfig files which apply to the file.
+func (file *File) applyEditorConfig(properties map[string]string) {
+	switch properties["indent_style"] {
+	case "tab":
+		file.setLocal("noexpandtab")
+	case "space":
+		file.setLocal("expandtab")
+	}
+	indentSize := properties["indent_size"]
+	if indentSize == "tab" {
+		file.setLocal("shiftwidth=0 softtabstop=0")
+	} else if size, err := strconv.Atoi(indentSize); err == nil && size > 0 {
+		file.setLocal("shiftwidth=" + indentSize + " softtabstop=-1")
+	}
+	tabWidth := properties["tab_width"]
+	if tabWidth == "" && indentSize != "tab" {
+		tabWidth = indentSize
+	}
+	if width, err := strconv.Atoi(tabWidth); err == nil && width > 0 {
+		file.setLocal("tabstop=" + tabWidth)
+	}
+	if format, ok := lineEndings[properties["end_of_line"]]; ok {
+		file.setLocal("fileformat=" + format)
+	}
+	if charset, ok := charsets[properties["charset"]]; ok {
+		file.setLocal("fileencoding=" + charset.encoding)
+		file.setBool("bomb", charset.bomb)
+	}
+	switch properties["insert_final_newline"] {
+	case "true":
+		file.setLocal("fixendofline")
+	case "false":
+		file.setLocal("nofixendofline")
+	}
+}
+
+func (file *File) setLocal(arguments string) {
+	_, _ = file.Options.SetLocal(arguments)
+}
+
+func (file *File) setBool(name string, on bool) {
+	if !on {
+		name = "no" + name
+	}
+	file.setLocal(name)
+}
+
+// trimTrailingWhitespace removes the whitespace at the end of each line,
+// keeping the cursor within its line.
+func (file *File) trimTrailingWhitespace() {
+	for traverse := file.First; traverse != nil; traverse = traverse.Next {
+		end := len(traverse.Data)
+		for end > 0 && unicode.IsSpace(traverse.Data[end-1]) {
+			end--
+		}
+		traverse.Data = traverse.Data[:end]
+	}
+	file.SetCursor(file.Cursor(), false)
+}
@@ -0,0 +1,137 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bkthomps/Ven/editorconfig"
+)
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestEditorConfigIndentation(t *testing.T) {
+	directory := t.TempDir()
+	writeTestFile(t, filepath.Join(directory, editorconfig.FileName), []byte("root = true\n[*.go]\nindent_style = space\nindent_size = 4\n[*.c]\nindent_style = tab\nindent_size = tab\ntab_width = 2\n"))
+	file := File{}
+	file.Init(filepath.Join(directory, "main.go"))
+	if !file.Options.Bool("expandtab") || file.ShiftWidth() != 4 || file.TabStop() != 4 || file.Options.Number("softtabstop") != -1 {
+		t.Error("bad indentation options for spaces")
+	}
+	file = File{}
+	file.Init(filepath.Join(directory, "main.c"))
+	if file.Options.Bool("expandtab") || file.ShiftWidth() != 2 || file.TabStop() != 2 {
+		t.Error("bad indentation options for tabs")
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "text")
+	writeTestFile(t, path, []byte("ab\r\ncd\r\n"))
+	file := File{}
+	file.Init(path)
+	if file.Lines != 2 || string(file.First.Data) != "ab" || file.Options.String("fileformat") != "dos" {
+		t.Error("bad dos file")
+	}
+	file.Current.AddAt(0, 'x')
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if text := readTestFile(t, path); text != "xab\r\ncd\r\n" {
+		t.Errorf("bad saved text: %q", text)
+	}
+	writeTestFile(t, path, []byte("ab\rcd"))
+	file = File{}
+	file.Init(path)
+	if file.Lines != 2 || file.Options.String("fileformat") != "mac" || file.Options.Bool("endofline") {
+		t.Error("bad mac file")
+	}
+}
+
+func TestEditorConfigSave(t *testing.T) {
+	directory := t.TempDir()
+	writeTestFile(t, filepath.Join(directory, editorconfig.FileName), []byte("root = true\n[*]\nend_of_line = crlf\ntrim_trailing_whitespace = true\ninsert_final_newline = false\n"))
+	path := filepath.Join(directory, "text")
+	writeTestFile(t, path, []byte("ab  \ncd\t\n"))
+	file := File{}
+	file.Init(path)
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if text := readTestFile(t, path); text != "ab\r\ncd\r\n" {
+		t.Errorf("bad saved text: %q", text)
+	}
+	if string(file.First.Data) != "ab" {
+		t.Error("trailing whitespace should be removed from the buffer")
+	}
+}
+
+func TestFinalNewline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "text")
+	writeTestFile(t, path, []byte("ab"))
+	file := File{}
+	file.Init(path)
+	if file.Options.Bool("endofline") {
+		t.Error("missing final newline should be found")
+	}
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if text := readTestFile(t, path); text != "ab\n" {
+		t.Errorf("fixendofline should add the final newline: %q", text)
+	}
+	_, _ = file.Options.Set("nofixeol")
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if text := readTestFile(t, path); text != "ab" {
+		t.Errorf("final newline should be left out: %q", text)
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	directory := t.TempDir()
+	writeTestFile(t, filepath.Join(directory, editorconfig.FileName), []byte("root = true\n[*.latin]\ncharset = latin1\n[*.bom]\ncharset = utf-8-bom\n"))
+	latin := filepath.Join(directory, "text.latin")
+	writeTestFile(t, latin, []byte{'c', 0xe9, '\n'})
+	file := File{}
+	file.Init(latin)
+	if string(file.First.Data) != "cé" {
+		t.Errorf("bad latin1 text: %q", string(file.First.Data))
+	}
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if text := readTestFile(t, latin); text != "c\xe9\n" {
+		t.Errorf("bad latin1 save: %q", text)
+	}
+	bom := filepath.Join(directory, "text.bom")
+	writeTestFile(t, bom, []byte("ab\n"))
+	file = File{}
+	file.Init(bom)
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if text := readTestFile(t, bom); text != "\xef\xbb\xbfab\n" {
+		t.Errorf("bad utf-8-bom save: %q", text)
+	}
+	utf16 := filepath.Join(directory, "text")
+	writeTestFile(t, utf16, []byte{0xff, 0xfe, 'a', 0, '\n', 0})
+	file = File{}
+	file.Init(utf16)
+	if string(file.First.Data) != "a" || file.Options.String("fileencoding") != "utf-16le" || !file.Options.Bool("bomb") {
+		t.Error("bad utf-16 file")
+	}
+}
@@ -0,0 +1,132 @@
+package buffer
+
+import (
+	"bytes"
+	"unicode/utf16"
+)
+
+var byteOrderMarks = []struct {
+	mark     []byte
+	encoding string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+	{[]byte{0xfe, 0xff}, "utf-16"},
+	{[]byte{0xff, 0xfe}, "utf-16le"},
+}
+
+// decode turns the bytes of a file into text. A byte order mark at the
+// start of the file takes precedence over the encoding it was given.
+func decode(data []byte, encoding string) (text []rune, detected string, bomb bool) {
+	for _, order := range byteOrderMarks {
+		if bytes.HasPrefix(data, order.mark) {
+			data = data[len(order.mark):]
+			encoding = order.encoding
+			bomb = true
+			break
+		}
+	}
+	switch encoding {
+	case "latin1":
+		text = make([]rune, len(data))
+		for i, b := range data {
+			text[i] = rune(b)
+		}
+	case "utf-16", "utf-16le":
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			high, low := data[2*i], data[2*i+1]
+			if encoding == "utf-16le" {
+				high, low = low, high
+			}
+			units[i] = uint16(high)<<8 | uint16(low)
+		}
+		text = utf16.Decode(units)
+	default:
+		encoding = "utf-8"
+		text = []rune(string(data))
+	}
+	return text, encoding, bomb
+}
+
+// encode is the opposite of decode. Characters which latin1 cannot hold
+// are written as a question mark.
+func encode(text []rune, encoding string, bomb bool) []byte {
+	data := make([]byte, 0, len(text))
+	if bomb {
+		for _, order := range byteOrderMarks {
+			if order.encoding == encoding {
+				data = append(data, order.mark...)
+			}
+		}
+	}
+	switch encoding {
+	case "latin1":
+		for _, r := range text {
+			if r > 0xff {
+				r = '?'
+			}
+			data = append(data, byte(r))
+		}
+	case "utf-16", "utf-16le":
+		for _, unit := range utf16.Encode(text) {
+			high, low := byte(unit>>8), byte(unit)
+			if encoding == "utf-16le" {
+				high, low = low, high
+			}
+			data = append(data, high, low)
+		}
+	default:
+		data = append(data, string(text)...)
+	}
+	return data
+}
+
+// detectFormat finds the line endings of the text, which are dos if every
+// line ends in a carriage return and a line feed, mac if there are only
+// carriage returns, and otherwise unix.
+func detectFormat(text []rune) string {
+	feeds, pairs, returns := 0, 0, 0
+	for i, r := range text {
+		switch r {
+		case '\n':
+			feeds++
+			if i > 0 && text[i-1] == '\r' {
+				pairs++
+			}
+		case '\r':
+			returns++
+		}
+	}
+	if feeds > 0 && pairs == feeds {
+		return "dos"
+	}
+	if feeds == 0 && returns > 0 {
+		return "mac"
+	}
+	return "unix"
+}
+
+// toLineFeeds changes the line endings of the format into line feeds.
+func toLineFeeds(text []rune, format string) []rune {
+	converted := make([]rune, 0, len(text))
+	for i, r := range text {
+		switch {
+		case format == "dos" && r == '\r' && i+1 < len(text) && text[i+1] == '\n':
+			continue
+		case format == "mac" && r == '\r':
+			r = '\n'
+		}
+		converted = append(converted, r)
+	}
+	return converted
+}
+
+func lineEnding(format string) []rune {
+	switch format {
+	case "dos":
+		return []rune{'\r', '\n'}
+	case "mac":
+		return []rune{'\r'}
+	}
+	return []rune{'\n'}
+}
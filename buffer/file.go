@@ -1,18 +1,21 @@
 package buffer
 
 import (
-	"fmt"
 	"io/ioutil"
 	"math"
-	"os"
 
+	"github.com/bkthomps/Ven/editorconfig"
+	"github.com/bkthomps/Ven/option"
 	"github.com/mattn/go-runewidth"
 )
 
+// TabSize is how wide a tab is where there is no buffer, such as on the
+// command line, and in a buffer when its tabstop option is not set.
 const TabSize = 8
 
 type File struct {
 	Name    string
+	Options *option.Options
 	mutated bool
 
 	First *Line
@@ -21,19 +24,36 @@ type File struct {
 	Current *Line
 	Lines   int
 
+	numbered *Line
+	number   int
+
 	runeOffset    int
 	spacingOffset int
+
+	keywords keywords
+	marks    map[rune]Position
+	changes  changeList
+
+	autoIndented *Line
 }
 
 func (file *File) Init(fileName string) {
 	file.Name = fileName
+	if file.Options == nil {
+		file.Options = &option.Options{}
+		file.Options.Init(nil)
+	}
+	file.setLocal("filetype=" + Filetype(fileName))
+	properties := editorconfig.Properties(fileName)
 	line := &Line{}
 	line.Init(nil, nil)
 	file.First = line
 	file.last = line
 	file.Current = line
 	file.Lines = 1
-	arr := readFile(fileName)
+	file.renumber(line, 1)
+	arr := file.readFile(fileName, charsets[properties["charset"]].encoding)
+	file.applyEditorConfig(properties)
 	for _, character := range arr[:len(arr)-1] {
 		file.Add(character)
 	}
@@ -41,16 +61,28 @@ func (file *File) Init(fileName string) {
 	file.runeOffset = 0
 	file.spacingOffset = 0
 	file.mutated = false
+	file.changes = changeList{}
 }
 
-func readFile(fileName string) (arr []rune) {
-	dat, err := ioutil.ReadFile(fileName)
-	data := ""
-	if !os.IsNotExist(err) {
-		data = string(dat)
+// readFile returns the text of the file with line feeds, ending in one.
+// The encoding, line endings and final newline which were found in the
+// file are kept in the options, so that saving writes them back.
+func (file *File) readFile(fileName, encoding string) []rune {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil || len(data) == 0 {
+		return []rune{'\n'}
+	}
+	if encoding == "" {
+		encoding = file.Options.String("fileencoding")
 	}
-	arr = []rune(data)
+	arr, encoding, bomb := decode(data, encoding)
+	file.setLocal("fileencoding=" + encoding)
+	file.setBool("bomb", bomb)
+	format := detectFormat(arr)
+	file.setLocal("fileformat=" + format)
+	arr = toLineFeeds(arr, format)
 	if len(arr) == 0 || arr[len(arr)-1] != '\n' {
+		file.setLocal("noendofline")
 		arr = append(arr, '\n')
 	}
 	return arr
@@ -60,45 +92,58 @@ func (file *File) CanSafeQuit() bool {
 	return !file.mutated
 }
 
+// Save writes the file with the encoding, line endings and final newline
+// of its options, after trimming trailing whitespace if the EditorConfig
+// files ask for it.
 func (file *File) Save() error {
-	osFile, err := os.Create(file.Name)
-	if err != nil {
-		return err
+	properties := editorconfig.Properties(file.Name)
+	if properties["trim_trailing_whitespace"] == "true" {
+		file.trimTrailingWhitespace()
 	}
+	ending := lineEnding(file.Options.String("fileformat"))
 	arr := make([]rune, 0)
 	for traverse := file.First; traverse != nil; traverse = traverse.Next {
 		arr = append(arr, traverse.Data...)
-		arr = append(arr, '\n')
+		if traverse.Next != nil || file.Options.Bool("endofline") || file.Options.Bool("fixendofline") {
+			arr = append(arr, ending...)
+		}
 	}
-	_, err = fmt.Fprintf(osFile, string(arr))
-	if err != nil {
+	data := encode(arr, file.Options.String("fileencoding"), file.Options.Bool("bomb"))
+	if err := ioutil.WriteFile(file.Name, data, 0666); err != nil {
 		return err
 	}
-	_ = osFile.Close()
 	file.mutated = false
 	return nil
 }
 
+// TabStop is how many columns wide a tab is in the buffer.
+func (file *File) TabStop() int {
+	if tabs := file.Options.Number("tabstop"); tabs > 0 {
+		return tabs
+	}
+	return TabSize
+}
+
 func (file *File) runeWidthIncrease(r rune) int {
-	return RuneWidthJump(r, file.spacingOffset)
+	return RuneWidthJump(r, file.spacingOffset, file.TabStop())
 }
 
-func RuneWidthJump(r rune, offset int) int {
+func RuneWidthJump(r rune, offset int, tabSize int) int {
 	if r == '\t' {
-		return int(math.Ceil(float64(offset+1)/float64(TabSize)) * TabSize)
+		return int(math.Ceil(float64(offset+1)/float64(tabSize)) * float64(tabSize))
 	}
 	return offset + runewidth.RuneWidth(r)
 }
 
 func (file *File) runeWidthDecrease(r rune) int {
-	return RuneWidthBackJump(r, file.Current.Data, file.runeOffset, file.spacingOffset)
+	return RuneWidthBackJump(r, file.Current.Data, file.runeOffset, file.spacingOffset, file.TabStop())
 }
 
-func RuneWidthBackJump(r rune, runes []rune, runeOffset, spacingOffset int) int {
+func RuneWidthBackJump(r rune, runes []rune, runeOffset, spacingOffset int, tabSize int) int {
 	if r == '\t' {
 		offset := 0
 		for i := 0; i < runeOffset; i++ {
-			offset = RuneWidthJump(runes[i], offset)
+			offset = RuneWidthJump(runes[i], offset, tabSize)
 		}
 		return offset
 	}
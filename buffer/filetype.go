@@ -0,0 +1,67 @@
+package buffer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+var extensionFiletypes = map[string]string{
+	".c":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".cs":    "cs",
+	".css":   "css",
+	".go":    "go",
+	".h":     "c",
+	".hpp":   "cpp",
+	".java":  "java",
+	".js":    "javascript",
+	".json":  "json",
+	".kt":    "kotlin",
+	".md":    "markdown",
+	".py":    "python",
+	".rs":    "rust",
+	".sh":    "sh",
+	".swift": "swift",
+	".ts":    "typescript",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+}
+
+var nameFiletypes = map[string]string{
+	"Makefile":   "make",
+	"makefile":   "make",
+	"Dockerfile": "dockerfile",
+}
+
+// Filetype returns the type of the named file from its extension, or an
+// empty string if it is not known.
+func Filetype(fileName string) string {
+	base := filepath.Base(fileName)
+	if filetype, ok := nameFiletypes[base]; ok {
+		return filetype
+	}
+	return extensionFiletypes[strings.ToLower(filepath.Ext(base))]
+}
+
+// indentRules is what smartindent indents after when it ends a line, and
+// dedents when it is typed at the start of a line.
+type indentRules struct {
+	openers string
+	closers string
+}
+
+var braceRules = indentRules{openers: "{[(", closers: "}])"}
+
+var filetypeRules = map[string]indentRules{
+	"markdown": {},
+	"python":   {openers: "{[(:", closers: "}])"},
+	"yaml":     {openers: ":"},
+}
+
+func (file *File) indentRules() indentRules {
+	if rules, ok := filetypeRules[file.Options.String("filetype")]; ok {
+		return rules
+	}
+	return braceRules
+}
@@ -0,0 +1,259 @@
+package buffer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Indentation is measured in columns, where a tab reaches the next
+// multiple of tabstop. Lines are indented with tabs and then spaces, or
+// only spaces when expandtab is set.
+
+// ShiftWidth is the number of columns which one level of indentation
+// takes, which is the tabstop when shiftwidth is zero.
+func (file *File) ShiftWidth() int {
+	if width := file.Options.Number("shiftwidth"); width > 0 {
+		return width
+	}
+	return file.TabStop()
+}
+
+// Indent returns the width of the whitespace at the start of the line.
+func (file *File) Indent(line *Line) int {
+	width := 0
+	for _, r := range line.Data[:line.FirstNonBlank()] {
+		if r == '\t' {
+			width += file.TabStop() - width%file.TabStop()
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// IndentText returns the whitespace which indents a line by the width.
+func (file *File) IndentText(width int) []rune {
+	return file.fillColumns(0, width, file.TabStop())
+}
+
+// SetIndent replaces the whitespace at the start of the line so that it
+// is indented by the width. A line which is only whitespace is emptied.
+func (file *File) SetIndent(line *Line, width int) {
+	if width < 0 {
+		width = 0
+	}
+	rest := line.Data[line.FirstNonBlank():]
+	if len(rest) == 0 {
+		width = 0
+	}
+	data := file.IndentText(width)
+	line.Data = append(data, rest...)
+	file.recordChange(Position{Line: line, Offset: len(data)})
+}
+
+// ShiftLines indents every line from first to last, inclusive, by the
+// number of levels, or removes indentation when it is negative. Empty
+// lines are left as they are. The cursor moves to the first non-blank
+// rune of the first line.
+func (file *File) ShiftLines(first, last *Line, levels int) {
+	for line := first; line != nil; line = line.Next {
+		if len(line.Data) > 0 {
+			file.SetIndent(line, file.Indent(line)+levels*file.ShiftWidth())
+		}
+		if line == last {
+			break
+		}
+	}
+	file.SetCursor(Position{Line: first, Offset: first.FirstNonBlank()}, false)
+}
+
+// ReindentLines indents every line from first to last, inclusive, for a
+// language with braces. Each line is indented one level more than the
+// line above it if that line opened a bracket, one level less if it
+// closed one, and one level less again if it starts with a closing
+// bracket. The cursor moves to the first non-blank rune of the first line.
+func (file *File) ReindentLines(first, last *Line) {
+	for line := first; line != nil; line = line.Next {
+		width := 0
+		if above := previousNonBlank(line); above != nil {
+			width = file.Indent(above) + bracketBalance(above.Data)*file.ShiftWidth()
+		}
+		if start := line.FirstNonBlank(); start < len(line.Data) && isClosingBracket(line.Data[start]) {
+			width -= file.ShiftWidth()
+		}
+		file.SetIndent(line, width)
+		if line == last {
+			break
+		}
+	}
+	file.SetCursor(Position{Line: first, Offset: first.FirstNonBlank()}, false)
+}
+
+func previousNonBlank(line *Line) *Line {
+	for above := line.Prev; above != nil; above = above.Prev {
+		if above.FirstNonBlank() < len(above.Data) {
+			return above
+		}
+	}
+	return nil
+}
+
+func isClosingBracket(r rune) bool {
+	return r == ')' || r == ']' || r == '}'
+}
+
+// bracketBalance is 1 if the line opens more brackets than it closes, -1
+// if it closes more, and otherwise 0. Brackets in strings and comments,
+// and closing brackets at the start of the line, are not counted.
+func bracketBalance(data []rune) int {
+	spans := stringSpans(data)
+	balance := 0
+	start := 0
+	for start < len(data) && (unicode.IsSpace(data[start]) || isClosingBracket(data[start])) {
+		start++
+	}
+	for i := start; i < len(data); i++ {
+		if inString(spans, i) {
+			continue
+		}
+		if data[i] == '/' && i+1 < len(data) && data[i+1] == '/' {
+			break
+		}
+		switch data[i] {
+		case '(', '[', '{':
+			balance++
+		case ')', ']', '}':
+			balance--
+		}
+	}
+	switch {
+	case balance > 0:
+		return 1
+	case balance < 0:
+		return -1
+	}
+	return 0
+}
+
+// Type adds the rune as if it were typed in insert mode. With autoindent,
+// a new line starts with the indentation of the line it was split from.
+// With smartindent, it is indented one level more after a line which ends
+// in an opening bracket, and a closing bracket which is typed at the start
+// of a line is indented to match its opening bracket.
+func (file *File) Type(character rune) (xPosition int, addedLine bool) {
+	if character == '\n' {
+		file.breakLine()
+		return file.spacingOffset, true
+	}
+	if character == '\t' {
+		file.typeTab()
+		return file.spacingOffset, false
+	}
+	xPosition, _ = file.Add(character)
+	start := file.Current.FirstNonBlank()
+	if file.Options.Bool("smartindent") && start == file.runeOffset-1 &&
+		strings.ContainsRune(file.indentRules().closers, character) {
+		file.dedentClosing()
+		xPosition = file.spacingOffset
+	}
+	return xPosition, false
+}
+
+// OpenLine adds a line below or above the current line, indented like
+// the current line when autoindent is set, and moves the cursor to it.
+func (file *File) OpenLine(above bool) (xPosition int) {
+	file.ClearAutoIndent()
+	current := file.Current
+	file.InsertLines([]rune{'\n'}, !above)
+	if file.Options.Bool("autoindent") {
+		width := file.Indent(current)
+		if !above && file.Options.Bool("smartindent") && file.endsWithOpener(current) {
+			width += file.ShiftWidth()
+		}
+		file.autoIndent(file.Current, width)
+	}
+	return file.spacingOffset
+}
+
+// ClearAutoIndent removes the indentation which autoindent added to the
+// current line if nothing else was typed on it, as when leaving insert mode.
+func (file *File) ClearAutoIndent() {
+	line := file.autoIndented
+	file.autoIndented = nil
+	if line != file.Current || line.FirstNonBlank() < len(line.Data) || len(line.Data) == 0 {
+		return
+	}
+	line.Data = []rune{}
+	file.runeOffset = 0
+	file.spacingOffset = 0
+	file.recordChange(file.Cursor())
+}
+
+func (file *File) breakLine() {
+	line := file.Current
+	carried := -1
+	if line == file.autoIndented && line.FirstNonBlank() == len(line.Data) {
+		carried = file.Indent(line)
+	}
+	file.ClearAutoIndent()
+	if carried > 0 {
+		file.Add('\n')
+		file.autoIndent(file.Current, carried)
+		return
+	}
+	if file.runeOffset == 0 || !file.Options.Bool("autoindent") {
+		file.Add('\n')
+		return
+	}
+	above := line
+	file.Add('\n')
+	line = file.Current
+	width := file.Indent(above)
+	if file.Options.Bool("smartindent") {
+		if file.endsWithOpener(above) {
+			width += file.ShiftWidth()
+		}
+		if start := line.FirstNonBlank(); start < len(line.Data) &&
+			strings.ContainsRune(file.indentRules().closers, line.Data[start]) {
+			width -= file.ShiftWidth()
+		}
+	}
+	file.autoIndent(line, width)
+}
+
+// autoIndent replaces the indentation of the line and moves the cursor to
+// the end of it.
+func (file *File) autoIndent(line *Line, width int) {
+	if width < 0 {
+		width = 0
+	}
+	indent := file.IndentText(width)
+	line.Data = append(indent, line.Data[line.FirstNonBlank():]...)
+	file.SetCursor(Position{Line: line, Offset: len(indent)}, true)
+	file.recordChange(file.Cursor())
+	if width > 0 {
+		file.autoIndented = line
+	}
+}
+
+func (file *File) endsWithOpener(line *Line) bool {
+	data := []rune(strings.TrimRightFunc(string(line.Data), unicode.IsSpace))
+	return len(data) > 0 && strings.ContainsRune(file.indentRules().openers, data[len(data)-1])
+}
+
+// dedentClosing indents the line of the closing bracket which was just
+// typed to match the line of its opening bracket.
+func (file *File) dedentClosing() {
+	closing := Position{Line: file.Current, Offset: file.runeOffset - 1}
+	width := file.Indent(file.Current) - file.ShiftWidth()
+	if open, ok := file.matchBracket(closing); ok {
+		width = file.Indent(open.Line)
+	}
+	if width < 0 {
+		width = 0
+	}
+	indent := file.IndentText(width)
+	file.Current.Data = append(indent, file.Current.Data[closing.Offset:]...)
+	file.SetCursor(Position{Line: file.Current, Offset: len(indent) + 1}, true)
+	file.recordChange(file.Cursor())
+}
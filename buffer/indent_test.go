@@ -0,0 +1,111 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/bkthomps/Ven/option"
+)
+
+func TestShiftLines(t *testing.T) {
+	file := fileWithText("a\n\n\tb\n    c")
+	_, _ = file.Options.Set("shiftwidth=4")
+	file.ShiftLines(file.First, file.last, 1)
+	if fileText(file) != "    a\n\n\t    b\n\tc\n" {
+		t.Errorf("bad shift right: %q", fileText(file))
+	}
+	file.ShiftLines(file.First, file.last, -1)
+	if fileText(file) != "a\n\n\tb\n    c\n" {
+		t.Errorf("bad shift left: %q", fileText(file))
+	}
+	if file.Current != file.First || file.Cursor().Offset != 0 {
+		t.Error("cursor should move to the first line")
+	}
+	file.ShiftLines(file.First, file.First, -1)
+	if fileText(file) != "a\n\n\tb\n    c\n" {
+		t.Errorf("should not shift left past the start: %q", fileText(file))
+	}
+}
+
+func TestShiftLinesExpandTab(t *testing.T) {
+	file := fileWithText("a\n\tb")
+	_, _ = file.Options.Set("shiftwidth=2 expandtab")
+	file.ShiftLines(file.First, file.last, 2)
+	if fileText(file) != "    a\n            b\n" {
+		t.Errorf("bad shift: %q", fileText(file))
+	}
+}
+
+func TestReindentLines(t *testing.T) {
+	text := "func f() {\nif x {\ny(\"{\")\n} else {\nz(a,\nb)\n}\n  \nreturn\n}"
+	file := fileWithText(text)
+	_, _ = file.Options.Set("shiftwidth=4 expandtab")
+	file.ReindentLines(file.First, file.last)
+	expected := "func f() {\n    if x {\n        y(\"{\")\n    } else {\n        z(a,\n            b)\n    }\n\n    return\n}\n"
+	if fileText(file) != expected {
+		t.Errorf("bad reindent: %q", fileText(file))
+	}
+}
+
+func typeText(file *File, text string) {
+	for _, r := range text {
+		file.Type(r)
+	}
+}
+
+func TestAutoIndent(t *testing.T) {
+	file := fileWithText("\tfoo")
+	file.EndOfLine(true)
+	typeText(file, "\nbar\n\n\nbaz")
+	if fileText(file) != "\tfoo\n\tbar\n\n\n\tbaz\n" {
+		t.Errorf("bad autoindent: %q", fileText(file))
+	}
+	file.Type('\n')
+	file.ClearAutoIndent()
+	if fileText(file) != "\tfoo\n\tbar\n\n\n\tbaz\n\n" {
+		t.Errorf("unused indentation should be removed: %q", fileText(file))
+	}
+}
+
+func TestSmartIndent(t *testing.T) {
+	file := fileWithText("")
+	_, _ = file.Options.Set("shiftwidth=4 expandtab")
+	typeText(file, "if x {\ny(\nz)\n}")
+	if fileText(file) != "if x {\n    y(\n        z)\n}\n" {
+		t.Errorf("bad smartindent: %q", fileText(file))
+	}
+	file.JumpToTop()
+	file.EndOfLine(true)
+	file.OpenLine(false)
+	if string(file.Current.Data) != "    " || file.Cursor().Offset != 4 {
+		t.Errorf("opened line should be indented: %q", string(file.Current.Data))
+	}
+}
+
+func TestSmartIndentFiletype(t *testing.T) {
+	file := fileWithText("")
+	_, _ = file.Options.Set("shiftwidth=2 expandtab filetype=yaml")
+	typeText(file, "a:\nb: c\nd")
+	if fileText(file) != "a:\n  b: c\n  d\n" {
+		t.Errorf("bad yaml indent: %q", fileText(file))
+	}
+	if Filetype("dir/main.go") != "go" || Filetype("Makefile") != "make" || Filetype("notes") != "" {
+		t.Error("bad filetype detection")
+	}
+}
+
+func TestFiletypeOfNextFile(t *testing.T) {
+	global := &option.Options{}
+	global.Init(nil)
+	first := &File{Options: &option.Options{}}
+	first.Options.Init(global)
+	first.Init("a.go")
+	_, _ = first.Options.Set("filetype=yaml")
+	for name, expected := range map[string]string{"b.go": "go", "notes": ""} {
+		next := &File{Options: &option.Options{}}
+		next.Options.Init(global)
+		next.Init(name)
+		if filetype := next.Options.String("filetype"); filetype != expected {
+			t.Errorf("%s: expected filetype %q, got %q", name, expected, filetype)
+		}
+	}
+}
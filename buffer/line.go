@@ -1,5 +1,7 @@
 package buffer
 
+import "unicode"
+
 type Line struct {
 	Data []rune
 	Next *Line
@@ -21,3 +23,14 @@ func (line *Line) AddAt(index int, character rune) {
 func (line *Line) RemoveAt(index int) {
 	line.Data = append(line.Data[:index], line.Data[index+1:]...)
 }
+
+// FirstNonBlank returns the offset of the first rune which is not
+// whitespace, or the length of the line if there is none.
+func (line *Line) FirstNonBlank() int {
+	for i, r := range line.Data {
+		if !unicode.IsSpace(r) {
+			return i
+		}
+	}
+	return len(line.Data)
+}
@@ -0,0 +1,53 @@
+package buffer
+
+import "sort"
+
+// Marks are positions which are kept on their line as lines are added or
+// removed around them. A mark on a line which is removed is removed too.
+
+func (file *File) SetMark(name rune, position Position) {
+	if file.marks == nil {
+		file.marks = make(map[rune]Position)
+	}
+	file.marks[name] = position
+}
+
+// Mark returns the position of the mark, keeping the offset within the
+// line, and reports whether the mark is set.
+func (file *File) Mark(name rune) (Position, bool) {
+	position, ok := file.marks[name]
+	if !ok {
+		return Position{}, false
+	}
+	if !file.Contains(position.Line) {
+		delete(file.marks, name)
+		return Position{}, false
+	}
+	return boundPosition(position), true
+}
+
+func (file *File) DeleteMark(name rune) {
+	delete(file.marks, name)
+}
+
+// MarkNames returns the name of every mark which is set, in order.
+func (file *File) MarkNames() []rune {
+	names := make([]rune, 0, len(file.marks))
+	for name := range file.marks {
+		if _, ok := file.Mark(name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Contains reports whether the line is still within the file.
+func (file *File) Contains(line *Line) bool {
+	for traverse := file.First; traverse != nil; traverse = traverse.Next {
+		if traverse == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package buffer
+
+import "testing"
+
+func TestMarkFollowsLine(t *testing.T) {
+	file := fileWithText("one\ntwo\nthree")
+	file.SetCursor(Position{Line: file.First.Next, Offset: 2}, false)
+	file.SetMark('a', file.Cursor())
+	file.JumpToTop()
+	file.InsertLines([]rune("new\n"), false)
+	position, ok := file.Mark('a')
+	if !ok || string(position.Line.Data) != "two" || position.Offset != 2 {
+		t.Error("mark should stay on its line when a line is added above")
+	}
+	if file.LineNumber(position.Line) != 3 {
+		t.Errorf("mark should have moved down: %d", file.LineNumber(position.Line))
+	}
+}
+
+func TestMarkKeptAtLineStartNewline(t *testing.T) {
+	file := fileWithText("one\ntwo")
+	file.SetMark('a', Position{Line: file.First.Next})
+	file.Down(false)
+	file.StartOfLine()
+	file.Add('\n')
+	position, ok := file.Mark('a')
+	if !ok || string(position.Line.Data) != "two" || file.Current != position.Line {
+		t.Error("a newline at the start of a line should keep the text on its line")
+	}
+	if fileText(file) != "one\n\ntwo\n" {
+		t.Errorf("bad text: %q", fileText(file))
+	}
+}
+
+func TestMarkRemovedWithLine(t *testing.T) {
+	file := fileWithText("one\ntwo\nthree")
+	file.SetMark('a', Position{Line: file.First.Next, Offset: 1})
+	file.SetMark('b', Position{Line: file.last, Offset: 9})
+	file.DeleteLines(file.First.Next, file.First.Next)
+	if _, ok := file.Mark('a'); ok {
+		t.Error("mark should be removed with its line")
+	}
+	position, ok := file.Mark('b')
+	if !ok || position.Offset != 4 {
+		t.Error("mark offset should be kept within the line")
+	}
+	names := file.MarkNames()
+	if len(names) != 1 || names[0] != 'b' {
+		t.Errorf("bad mark names: %q", string(names))
+	}
+}
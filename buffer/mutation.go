@@ -1,20 +1,28 @@
 package buffer
 
+import "unicode"
+
 func (file *File) Add(character rune) (xPosition int, addedLine bool) {
-	file.mutated = true
 	if character == '\n' {
 		file.addLine()
 		file.runeOffset = 0
 		file.spacingOffset = 0
+		file.recordChange(Position{Line: file.Current})
 		return file.spacingOffset, true
 	}
 	file.spacingOffset = file.runeWidthIncrease(character)
 	file.Current.AddAt(file.runeOffset, character)
+	file.recordChange(file.Cursor())
 	file.runeOffset++
 	return file.spacingOffset, false
 }
 
 func (file *File) addLine() {
+	if file.runeOffset == 0 {
+		file.addLineAbove()
+		return
+	}
+	number := file.LineNumber(file.Current)
 	line := &Line{}
 	line.Init(file.Current.Next, file.Current)
 	if file.Current.Next != nil {
@@ -25,28 +33,44 @@ func (file *File) addLine() {
 	file.Current.Next = line
 	file.Current = line
 	file.Lines++
-	if file.runeOffset == 0 {
-		line.Data = line.Prev.Data
-		line.Prev.Data = make([]rune, 0)
-	} else if file.runeOffset < len(file.Current.Data)-1 {
-		line.Data = line.Prev.Data[file.runeOffset:]
-		line.Prev.Data = line.Prev.Data[:file.runeOffset]
+	file.renumber(line, number+1)
+	if file.runeOffset < len(line.Prev.Data) {
+		line.Data = append(line.Data, line.Prev.Data[file.runeOffset:]...)
+		line.Prev.Data = line.Prev.Data[:file.runeOffset:file.runeOffset]
+	}
+}
+
+// addLineAbove adds an empty line above the current line, which is what
+// a newline at the start of a line does. The text stays on its line, so
+// that marks on it are kept.
+func (file *File) addLineAbove() {
+	number := file.LineNumber(file.Current)
+	line := &Line{}
+	line.Init(file.Current, file.Current.Prev)
+	if file.Current.Prev != nil {
+		file.Current.Prev.Next = line
+	} else {
+		file.First = line
 	}
+	file.Current.Prev = line
+	file.Lines++
+	file.renumber(file.Current, number+1)
 }
 
 func (file *File) Remove() (xPosition int) {
 	if len(file.Current.Data) == 0 {
 		return file.spacingOffset
 	}
-	file.mutated = true
 	if file.runeOffset > 0 && file.runeOffset == len(file.Current.Data)-1 {
 		r := file.Current.Data[file.runeOffset]
 		file.spacingOffset = file.runeWidthDecrease(r)
 		file.Current.RemoveAt(file.runeOffset)
 		file.runeOffset--
+		file.recordChange(file.Cursor())
 		return file.spacingOffset
 	}
 	file.Current.RemoveAt(file.runeOffset)
+	file.recordChange(file.Cursor())
 	return file.spacingOffset
 }
 
@@ -58,20 +82,22 @@ func (file *File) RemoveBefore() (xPosition int) {
 	r := file.Current.Data[file.runeOffset]
 	file.spacingOffset = file.runeWidthDecrease(r)
 	file.Current.RemoveAt(file.runeOffset)
-	file.mutated = true
+	file.recordChange(file.Cursor())
 	return file.spacingOffset
 }
 
 func (file *File) Backspace() (xPosition int, deletedLine bool) {
-	file.mutated = true
 	if file.runeOffset == 0 {
 		if file.Current == file.First {
 			return file.spacingOffset, false
 		}
 		file.spacingOffset = 1_000_000_000
 		current := file.Current
+		number := file.LineNumber(current)
 		file.Current = current.Prev
 		file.calculateOffset(true)
+		file.moveChanges(current, current, Position{Line: current.Prev, Offset: len(current.Prev.Data)})
+		file.recordChange(Position{Line: current.Prev, Offset: len(current.Prev.Data)})
 		current.Prev.Data = append(current.Prev.Data, current.Data...)
 		current.Prev.Next = current.Next
 		if current.Next != nil {
@@ -80,28 +106,36 @@ func (file *File) Backspace() (xPosition int, deletedLine bool) {
 			file.last = file.Current
 		}
 		file.Lines--
+		file.renumber(file.Current, number-1)
 		return file.spacingOffset, true
 	}
 	file.runeOffset--
 	r := file.Current.Data[file.runeOffset]
 	file.spacingOffset = file.runeWidthDecrease(r)
 	file.Current.RemoveAt(file.runeOffset)
+	file.recordChange(file.Cursor())
 	return file.spacingOffset, false
 }
 
 func (file *File) RemoveLine(isInsert bool) (xPosition int, wasFirst bool, wasLast bool) {
-	file.mutated = true
+	removed := file.Current
+	defer func() {
+		file.moveChanges(removed, removed, Position{Line: file.Current})
+		file.recordChange(Position{Line: file.Current})
+	}()
 	if file.Current.Prev == nil && file.Current.Next == nil {
 		file.Current.Data = []rune{}
 		file.runeOffset = 0
 		file.spacingOffset = 0
 		return file.spacingOffset, false, false
 	}
+	number := file.LineNumber(file.Current)
 	if file.Current.Prev == nil {
 		file.Current = file.Current.Next
 		file.Current.Prev = nil
 		file.First = file.Current
 		file.Lines--
+		file.renumber(file.Current, 1)
 		file.calculateOffset(isInsert)
 		return file.spacingOffset, true, false
 	}
@@ -110,6 +144,7 @@ func (file *File) RemoveLine(isInsert bool) (xPosition int, wasFirst bool, wasLa
 		file.Current.Next = nil
 		file.last = file.Current
 		file.Lines--
+		file.renumber(file.Current, number-1)
 		file.calculateOffset(isInsert)
 		return file.spacingOffset, false, true
 	}
@@ -118,12 +153,13 @@ func (file *File) RemoveLine(isInsert bool) (xPosition int, wasFirst bool, wasLa
 	deleteNode.Next.Prev = deleteNode.Prev
 	file.Current = deleteNode.Next
 	file.Lines--
+	file.renumber(file.Current, number)
 	file.calculateOffset(isInsert)
 	return file.spacingOffset, false, false
 }
 
 func (file *File) RemoveRestOfLine(isInsert bool) (xPosition int) {
-	file.mutated = true
+	file.recordChange(file.Cursor())
 	if file.runeOffset == 0 {
 		file.Current.Data = []rune{}
 		file.runeOffset = 0
@@ -134,3 +170,259 @@ func (file *File) RemoveRestOfLine(isInsert bool) (xPosition int) {
 	file.calculateOffset(isInsert)
 	return file.spacingOffset
 }
+
+// ReplaceRune overwrites the rune under the cursor without moving the
+// cursor, and returns the rune which was there.
+func (file *File) ReplaceRune(character rune) (original rune) {
+	original = file.Current.Data[file.runeOffset]
+	file.Current.Data[file.runeOffset] = character
+	file.recordChange(file.Cursor())
+	return original
+}
+
+// Transform replaces each rune from start up to, but not including, end
+// with what the transform returns for it, such as the rune in upper case.
+func (file *File) Transform(start, end Position, transform func(rune) rune) {
+	for line := start.Line; line != nil; line = line.Next {
+		from, to := 0, len(line.Data)
+		if line == start.Line {
+			from = boundOffset(start.Offset, line)
+		}
+		if line == end.Line {
+			to = boundOffset(end.Offset, line)
+		}
+		for i := from; i < to; i++ {
+			line.Data[i] = transform(line.Data[i])
+		}
+		if line == end.Line {
+			break
+		}
+	}
+	file.recordChange(Position{Line: start.Line, Offset: start.Offset})
+}
+
+// JoinLines joins the count lines starting with the first line into one,
+// or as many as there are. Unless the whitespace is kept, the indentation
+// of each joined line is removed and a space goes between the lines,
+// except after whitespace or before a closing parenthesis. The cursor
+// moves to where the last lines were joined. It reports false if there is
+// no line after the first line.
+func (file *File) JoinLines(first *Line, count int, keepWhitespace bool) bool {
+	if first.Next == nil {
+		return false
+	}
+	number := file.LineNumber(first)
+	joined := Position{Line: first}
+	for i := 1; i < count && first.Next != nil; i++ {
+		next := first.Next
+		data := next.Data
+		joined.Offset = len(first.Data)
+		if !keepWhitespace {
+			data = data[next.FirstNonBlank():]
+			if len(first.Data) > 0 && !unicode.IsSpace(first.Data[len(first.Data)-1]) &&
+				len(data) > 0 && data[0] != ')' {
+				first.Data = append(first.Data, ' ')
+			}
+		}
+		file.moveChanges(next, next, joined)
+		first.Data = append(first.Data, data...)
+		first.Next = next.Next
+		if next.Next == nil {
+			file.last = first
+		} else {
+			next.Next.Prev = first
+		}
+		file.Lines--
+	}
+	file.renumber(first, number)
+	file.recordChange(joined)
+	file.SetCursor(joined, false)
+	return true
+}
+
+// InsertText adds the runes at the cursor, as if they were typed in
+// insert mode, and leaves the cursor after them.
+func (file *File) InsertText(text []rune) {
+	for _, r := range text {
+		file.Add(r)
+	}
+}
+
+// InsertLines adds the text, which is made up of lines that each end in
+// a newline, above or below the current line. The cursor moves to the
+// start of the first added line.
+func (file *File) InsertLines(text []rune, below bool) {
+	if len(text) == 0 {
+		return
+	}
+	if text[len(text)-1] == '\n' {
+		text = text[:len(text)-1]
+	}
+	number := file.LineNumber(file.Current)
+	prev := file.Current.Prev
+	next := file.Current
+	if below {
+		prev = file.Current
+		next = file.Current.Next
+		number++
+	}
+	var first *Line
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && text[i] != '\n' {
+			continue
+		}
+		line := &Line{}
+		line.Init(next, prev)
+		line.Data = append(line.Data, text[start:i]...)
+		if prev == nil {
+			file.First = line
+		} else {
+			prev.Next = line
+		}
+		if first == nil {
+			first = line
+		}
+		prev = line
+		start = i + 1
+		file.Lines++
+	}
+	if next == nil {
+		file.last = prev
+	} else {
+		next.Prev = prev
+	}
+	file.Current = first
+	file.renumber(first, number)
+	file.runeOffset = 0
+	file.spacingOffset = 0
+	file.recordChange(Position{Line: first})
+}
+
+// Text returns the runes from start up to, but not including, end, with
+// a newline between each line.
+func (file *File) Text(start, end Position) []rune {
+	text := make([]rune, 0)
+	for line := start.Line; line != nil; line = line.Next {
+		from := 0
+		if line == start.Line {
+			from = boundOffset(start.Offset, line)
+		}
+		if line == end.Line {
+			to := boundOffset(end.Offset, line)
+			if to > from {
+				text = append(text, line.Data[from:to]...)
+			}
+			return text
+		}
+		text = append(text, line.Data[from:]...)
+		text = append(text, '\n')
+	}
+	return text
+}
+
+// LinesText returns every line from first to last, inclusive, each
+// followed by a newline.
+func (file *File) LinesText(first, last *Line) []rune {
+	text := make([]rune, 0)
+	for line := first; line != nil; line = line.Next {
+		text = append(text, line.Data...)
+		text = append(text, '\n')
+		if line == last {
+			break
+		}
+	}
+	return text
+}
+
+// Delete removes the runes from start up to, but not including, end,
+// joining the lines in between, and moves the cursor to start.
+func (file *File) Delete(start, end Position, isInsert bool) (xPosition int) {
+	from := boundOffset(start.Offset, start.Line)
+	to := boundOffset(end.Offset, end.Line)
+	data := make([]rune, 0, from+len(end.Line.Data)-to)
+	data = append(data, start.Line.Data[:from]...)
+	data = append(data, end.Line.Data[to:]...)
+	if start.Line != end.Line {
+		number := file.LineNumber(start.Line)
+		file.moveChanges(start.Line.Next, end.Line, Position{Line: start.Line, Offset: from})
+		removed := 0
+		for line := start.Line.Next; line != end.Line.Next; line = line.Next {
+			removed++
+		}
+		start.Line.Next = end.Line.Next
+		if end.Line.Next == nil {
+			file.last = start.Line
+		} else {
+			end.Line.Next.Prev = start.Line
+		}
+		file.Lines -= removed
+		file.renumber(start.Line, number)
+	}
+	start.Line.Data = data
+	file.recordChange(Position{Line: start.Line, Offset: from})
+	return file.SetCursor(Position{Line: start.Line, Offset: from}, isInsert)
+}
+
+// DeleteLines removes every line from first to last, inclusive, and
+// moves the cursor to the start of the line after them. The file always
+// keeps at least one line.
+func (file *File) DeleteLines(first, last *Line) {
+	number := file.LineNumber(first)
+	before := first.Prev
+	after := last.Next
+	if before == nil && after == nil {
+		if first.Next != nil {
+			file.moveChanges(first.Next, last, Position{Line: first})
+		}
+		first.Data = []rune{}
+		first.Next = nil
+		file.last = first
+		file.Lines = 1
+		file.Current = first
+		file.renumber(first, 1)
+	} else {
+		removed := 0
+		for line := first; line != after; line = line.Next {
+			removed++
+		}
+		if before == nil {
+			file.First = after
+		} else {
+			before.Next = after
+		}
+		if after == nil {
+			file.last = before
+			file.Current = before
+			number--
+		} else {
+			after.Prev = before
+			file.Current = after
+		}
+		file.Lines -= removed
+		file.renumber(file.Current, number)
+		file.moveChanges(first, last, Position{Line: file.Current})
+	}
+	file.runeOffset = 0
+	file.spacingOffset = 0
+	file.recordChange(Position{Line: file.Current})
+}
+
+func boundOffset(offset int, line *Line) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset > len(line.Data) {
+		return len(line.Data)
+	}
+	return offset
+}
+
+// boundPosition keeps the offset of the position on a rune of its line.
+func boundPosition(position Position) Position {
+	position.Offset = boundOffset(position.Offset, position.Line)
+	if position.Offset == len(position.Line.Data) && position.Offset > 0 {
+		position.Offset--
+	}
+	return position
+}
@@ -2,6 +2,7 @@ package buffer
 
 import (
 	"testing"
+	"unicode"
 )
 
 func TestAddCharacters(t *testing.T) {
@@ -388,3 +389,170 @@ func TestRemoveRestOfLineNonZeroOffset(t *testing.T) {
 		t.Errorf("should have deleted to the right: got size %d", len(file.First.Data))
 	}
 }
+
+func fileWithText(text string) *File {
+	file := &File{}
+	file.Init("")
+	file.InsertText([]rune(text))
+	file.JumpToTop()
+	return file
+}
+
+func fileText(file *File) string {
+	return string(file.LinesText(file.First, file.last))
+}
+
+func TestAddNewlineSplitsLine(t *testing.T) {
+	file := fileWithText("abcd")
+	file.Right(false)
+	file.Right(false)
+	file.Add('\n')
+	if fileText(file) != "ab\ncd\n" {
+		t.Errorf("bad split: %q", fileText(file))
+	}
+	file.Up(true)
+	file.EndOfLine(true)
+	file.Add('X')
+	if fileText(file) != "abX\ncd\n" {
+		t.Errorf("lines should not share data: %q", fileText(file))
+	}
+}
+
+func TestInsertText(t *testing.T) {
+	file := fileWithText("one\ntwo\nthree")
+	if fileText(file) != "one\ntwo\nthree\n" {
+		t.Errorf("bad text: %q", fileText(file))
+	}
+	if file.Lines != 3 {
+		t.Error("expected three lines")
+	}
+}
+
+func TestInsertLines(t *testing.T) {
+	file := fileWithText("one\ntwo")
+	file.InsertLines([]rune("a\nb\n"), true)
+	if fileText(file) != "one\na\nb\ntwo\n" {
+		t.Errorf("bad text: %q", fileText(file))
+	}
+	if string(file.Current.Data) != "a" || file.Lines != 4 {
+		t.Error("bad cursor or line count")
+	}
+	file.JumpToTop()
+	file.InsertLines([]rune("zero\n"), false)
+	if fileText(file) != "zero\none\na\nb\ntwo\n" {
+		t.Errorf("bad text: %q", fileText(file))
+	}
+	if file.First != file.Current {
+		t.Error("first line should be updated")
+	}
+	file.JumpToBottom()
+	file.InsertLines([]rune("end\n"), true)
+	if file.last != file.Current || string(file.last.Data) != "end" {
+		t.Error("last line should be updated")
+	}
+}
+
+func TestTextAcrossLines(t *testing.T) {
+	file := fileWithText("one\ntwo\nthree")
+	second := file.First.Next
+	text := file.Text(Position{Line: file.First, Offset: 1}, Position{Line: second.Next, Offset: 2})
+	if string(text) != "ne\ntwo\nth" {
+		t.Errorf("bad text: %q", string(text))
+	}
+	text = file.Text(Position{Line: second, Offset: 1}, Position{Line: second, Offset: 10})
+	if string(text) != "wo" {
+		t.Errorf("bad text: %q", string(text))
+	}
+}
+
+func TestDeleteWithinLine(t *testing.T) {
+	file := fileWithText("abcdef")
+	x := file.Delete(Position{Line: file.First, Offset: 1}, Position{Line: file.First, Offset: 3}, false)
+	if fileText(file) != "adef\n" || x != 1 {
+		t.Errorf("bad delete: %q", fileText(file))
+	}
+	x = file.Delete(Position{Line: file.First, Offset: 2}, Position{Line: file.First, Offset: 4}, false)
+	if fileText(file) != "ad\n" || x != 1 {
+		t.Errorf("cursor should stay on the line: %q %d", fileText(file), x)
+	}
+	if file.CanSafeQuit() {
+		t.Error("should not be able to safe quit")
+	}
+}
+
+func TestDeleteAcrossLines(t *testing.T) {
+	file := fileWithText("one\ntwo\nthree\nfour")
+	third := file.First.Next.Next
+	file.Delete(Position{Line: file.First, Offset: 2}, Position{Line: third, Offset: 3}, false)
+	if fileText(file) != "onee\nfour\n" || file.Lines != 2 {
+		t.Errorf("bad delete: %q", fileText(file))
+	}
+	if file.First.Next.Prev != file.First {
+		t.Error("bad links")
+	}
+	file.Delete(Position{Line: file.First, Offset: 4}, Position{Line: file.last, Offset: 4}, false)
+	if fileText(file) != "onee\n" || file.last != file.First || file.Lines != 1 {
+		t.Errorf("bad delete: %q", fileText(file))
+	}
+}
+
+func TestDeleteLines(t *testing.T) {
+	file := fileWithText("one\ntwo\nthree\nfour")
+	second := file.First.Next
+	file.DeleteLines(second, second.Next)
+	if fileText(file) != "one\nfour\n" || file.Lines != 2 {
+		t.Errorf("bad delete: %q", fileText(file))
+	}
+	if string(file.Current.Data) != "four" {
+		t.Error("cursor should move to the next line")
+	}
+	file.DeleteLines(file.last, file.last)
+	if string(file.Current.Data) != "one" || file.last != file.First {
+		t.Error("cursor should move to the previous line")
+	}
+	file.DeleteLines(file.First, file.last)
+	if fileText(file) != "\n" || file.Lines != 1 {
+		t.Errorf("should keep one empty line: %q", fileText(file))
+	}
+}
+
+func TestReplaceRune(t *testing.T) {
+	file := fileWithText("abc")
+	file.Right(false)
+	if original := file.ReplaceRune('x'); original != 'b' {
+		t.Errorf("bad original rune: %q", original)
+	}
+	if fileText(file) != "axc\n" || file.Cursor().Offset != 1 || file.CanSafeQuit() {
+		t.Errorf("bad replace: %q", fileText(file))
+	}
+}
+
+func TestTransformAcrossLines(t *testing.T) {
+	file := fileWithText("abc\ndef\nghi")
+	start := Position{Line: file.First, Offset: 1}
+	end := Position{Line: file.last, Offset: 2}
+	file.Transform(start, end, unicode.ToUpper)
+	if fileText(file) != "aBC\nDEF\nGHi\n" {
+		t.Errorf("bad transform: %q", fileText(file))
+	}
+}
+
+func TestJoinLines(t *testing.T) {
+	file := fileWithText("a\n\tb \nc\n)\n\nd")
+	if !file.JoinLines(file.First, 5, false) {
+		t.Fatal("lines should join")
+	}
+	if fileText(file) != "a b c)\nd\n" || file.Lines != 2 {
+		t.Errorf("bad join: %q", fileText(file))
+	}
+	if file.Cursor().Offset != 5 {
+		t.Errorf("cursor should be where the last lines joined: %d", file.Cursor().Offset)
+	}
+	file.JoinLines(file.First, 2, true)
+	if fileText(file) != "a b c)d\n" {
+		t.Errorf("bad join keeping whitespace: %q", fileText(file))
+	}
+	if file.JoinLines(file.First, 2, false) {
+		t.Error("the last line should not join")
+	}
+}
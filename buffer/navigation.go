@@ -1,6 +1,87 @@
 package buffer
 
-import "unicode"
+// Position is the location of a rune within the file.
+type Position struct {
+	Line   *Line
+	Offset int
+}
+
+func (file *File) Cursor() Position {
+	return Position{Line: file.Current, Offset: file.runeOffset}
+}
+
+// SetCursor moves the cursor to the position, keeping it within the line.
+// In insert mode, the cursor may also be just after the last rune.
+func (file *File) SetCursor(position Position, isInsert bool) (xPosition int) {
+	file.Current = position.Line
+	file.runeOffset = position.Offset
+	maxOffset := len(file.Current.Data) - 1
+	if isInsert {
+		maxOffset++
+	}
+	if file.runeOffset > maxOffset {
+		file.runeOffset = maxOffset
+	}
+	if file.runeOffset < 0 {
+		file.runeOffset = 0
+	}
+	return file.setSpacingOffset()
+}
+
+func (file *File) XPosition() int {
+	return file.spacingOffset
+}
+
+// Compare returns a negative number if a is before b, zero if they are
+// the same position, and a positive number if a is after b.
+func (file *File) Compare(a, b Position) int {
+	if a.Line == b.Line {
+		return a.Offset - b.Offset
+	}
+	for traverse := a.Line; traverse != nil; traverse = traverse.Next {
+		if traverse == b.Line {
+			return -1
+		}
+	}
+	return 1
+}
+
+// LineAt returns the line with the one-based index, or the last line if
+// the file is not that long. It counts from the first line, the last line
+// or the last line which was numbered, whichever is closest.
+func (file *File) LineAt(number int) *Line {
+	if number <= 1 {
+		return file.First
+	}
+	if number >= file.Lines {
+		return file.last
+	}
+	if file.numbered == nil {
+		file.renumber(file.First, 1)
+	}
+	line, at := file.numbered, file.number
+	if distance(number, 1) < distance(number, at) {
+		line, at = file.First, 1
+	}
+	if distance(number, file.Lines) < distance(number, at) {
+		line, at = file.last, file.Lines
+	}
+	for ; at < number; at++ {
+		line = line.Next
+	}
+	for ; at > number; at-- {
+		line = line.Prev
+	}
+	file.renumber(line, number)
+	return line
+}
+
+func distance(a, b int) int {
+	if a < b {
+		return b - a
+	}
+	return a - b
+}
 
 func (file *File) Left() (xPosition int) {
 	if file.runeOffset == 0 {
@@ -79,6 +160,42 @@ func (file *File) EndOfLine(isInsert bool) (xPosition int) {
 	return file.spacingOffset
 }
 
+// LineNumber returns the one-based index of the line within the file. It
+// counts both ways from the last line which was numbered, which is near the
+// cursor and the top of the screen, so that it does not walk the whole file
+// each time the cursor moves.
+func (file *File) LineNumber(line *Line) int {
+	if file.numbered == nil {
+		file.renumber(file.First, 1)
+	}
+	up, down := file.numbered, file.numbered
+	for distance := 0; up != nil || down != nil; distance++ {
+		if down != nil && down == line {
+			file.renumber(line, file.number+distance)
+			return file.number
+		}
+		if up != nil && up == line {
+			file.renumber(line, file.number-distance)
+			return file.number
+		}
+		if down != nil {
+			down = down.Next
+		}
+		if up != nil {
+			up = up.Prev
+		}
+	}
+	return file.Lines + 1
+}
+
+// renumber records the number of a line, which LineNumber counts from.
+// Whatever adds or removes lines sets it again, since the number it held
+// may no longer be right.
+func (file *File) renumber(line *Line, number int) {
+	file.numbered = line
+	file.number = number
+}
+
 func (file *File) JumpToTop() (xPosition int) {
 	file.Current = file.First
 	return file.StartOfLine()
@@ -91,30 +208,15 @@ func (file *File) JumpToBottom() (xPosition int) {
 
 // NextWordStart will move the cursor to the start of the next word,
 // unless there is no next word, in which case the cursor moves to
-// the end of the file.
+// the end of the file. An empty line also counts as a word.
 func (file *File) NextWordStart() (xPosition int, linesDown int) {
-	linesDown = 0
-	for file.isNotWhitespace(file.runeOffset) {
-		if file.isOutOfBounds(file.runeOffset) {
-			if file.Current.Next == nil {
-				return file.setBoundedOffsets(), linesDown
-			}
-			linesDown = file.moveLineDown(linesDown)
-			break
-		}
-		file.runeOffset++
-	}
-	for file.isWhitespace(file.runeOffset) {
-		if file.isOutOfBounds(file.runeOffset) {
-			if file.Current.Next == nil {
-				return file.setBoundedOffsets(), linesDown
-			}
-			linesDown = file.moveLineDown(linesDown)
-			continue
-		}
-		file.runeOffset++
-	}
-	return file.setBoundedOffsets(), linesDown
+	return file.moveTo(file.nextWordStart(false))
+}
+
+// NextBigWordStart is NextWordStart for a WORD, which is any run of
+// runes that are not blank.
+func (file *File) NextBigWordStart() (xPosition int, linesDown int) {
+	return file.moveTo(file.nextWordStart(true))
 }
 
 // PrevWordStart will move the cursor to the start of the current word,
@@ -122,27 +224,12 @@ func (file *File) NextWordStart() (xPosition int, linesDown int) {
 // which case it will move to the start of the previous word, if there
 // is one, otherwise it will move the cursor to the start of the file.
 func (file *File) PrevWordStart() (xPosition int, linesUp int) {
-	linesUp = 0
-	if file.runeOffset >= 0 {
-		file.runeOffset--
-	}
-	for file.isWhitespace(file.runeOffset) {
-		if file.isOutOfBounds(file.runeOffset) {
-			if file.Current.Prev == nil {
-				return file.setBoundedOffsets(), linesUp
-			}
-			linesUp = file.moveLineUp(linesUp)
-			continue
-		}
-		file.runeOffset--
-	}
-	for file.isNotWhitespace(file.runeOffset - 1) {
-		if file.isOutOfBounds(file.runeOffset - 1) {
-			return file.setBoundedOffsets(), linesUp
-		}
-		file.runeOffset--
-	}
-	return file.setBoundedOffsets(), linesUp
+	return file.moveTo(file.prevWordStart(false))
+}
+
+// PrevBigWordStart is PrevWordStart for a WORD.
+func (file *File) PrevBigWordStart() (xPosition int, linesUp int) {
+	return file.moveTo(file.prevWordStart(true))
 }
 
 // NextWordEnd will move the cursor to the end of the current word,
@@ -150,52 +237,140 @@ func (file *File) PrevWordStart() (xPosition int, linesUp int) {
 // which case it will move to the end of the next word, if there
 // is one, otherwise it will move the cursor to the end of the file.
 func (file *File) NextWordEnd() (xPosition int, linesDown int) {
-	linesDown = 0
-	if file.runeOffset < len(file.Current.Data) {
-		file.runeOffset++
-	}
-	for file.isWhitespace(file.runeOffset) {
-		if file.isOutOfBounds(file.runeOffset) {
-			if file.Current.Next == nil {
-				return file.setBoundedOffsets(), linesDown
+	return file.moveTo(file.nextWordEnd(false))
+}
+
+// NextBigWordEnd is NextWordEnd for a WORD.
+func (file *File) NextBigWordEnd() (xPosition int, linesDown int) {
+	return file.moveTo(file.nextWordEnd(true))
+}
+
+// NextParagraph will move the cursor to the next empty line after a
+// paragraph, or to the end of the file if there is none.
+func (file *File) NextParagraph() (xPosition int, linesDown int) {
+	return file.moveTo(file.paragraphBoundary(true))
+}
+
+// PrevParagraph will move the cursor to the previous empty line before a
+// paragraph, or to the start of the file if there is none.
+func (file *File) PrevParagraph() (xPosition int, linesUp int) {
+	return file.moveTo(file.paragraphBoundary(false))
+}
+
+func (file *File) paragraphBoundary(forward bool) Position {
+	line := file.Current
+	inParagraph := len(line.Data) > 0
+	for {
+		next := line.Prev
+		if forward {
+			next = line.Next
+		}
+		if next == nil {
+			if forward {
+				return Position{Line: line, Offset: len(line.Data)}
 			}
-			linesDown = file.moveLineDown(linesDown)
-			continue
+			return Position{Line: line}
+		}
+		line = next
+		if len(line.Data) > 0 {
+			inParagraph = true
+		} else if inParagraph {
+			return Position{Line: line}
 		}
-		file.runeOffset++
 	}
-	for file.isNotWhitespace(file.runeOffset + 1) {
-		if file.isOutOfBounds(file.runeOffset + 1) {
-			return file.setBoundedOffsets(), linesDown
+}
+
+// NextSentence will move the cursor to the start of the next sentence,
+// or to the end of the file if there is none. An empty line counts as
+// a sentence.
+func (file *File) NextSentence() (xPosition int, linesDown int) {
+	text, positions := file.flatten()
+	index := indexOf(positions, file.Cursor())
+	for _, start := range sentenceStarts(text) {
+		if start > index {
+			return file.moveTo(positions[start])
 		}
-		file.runeOffset++
 	}
-	return file.setBoundedOffsets(), linesDown
+	last := positions[len(positions)-1]
+	return file.moveTo(last)
 }
 
-func (file *File) isWhitespace(index int) bool {
-	return file.isOutOfBounds(index) || unicode.IsSpace(file.Current.Data[index])
+// PrevSentence will move the cursor to the start of the current sentence,
+// unless the cursor is already there, in which case it will move to the
+// start of the previous sentence, or the start of the file.
+func (file *File) PrevSentence() (xPosition int, linesUp int) {
+	text, positions := file.flatten()
+	index := indexOf(positions, file.Cursor())
+	target := positions[0]
+	for _, start := range sentenceStarts(text) {
+		if start >= index {
+			break
+		}
+		target = positions[start]
+	}
+	return file.moveTo(target)
 }
 
-func (file *File) isNotWhitespace(index int) bool {
-	return file.isOutOfBounds(index) || !unicode.IsSpace(file.Current.Data[index])
+// FindForward will move the cursor to the count'th occurrence of the rune
+// after the cursor on the current line, or just before it if till is
+// true. The cursor does not move if there are not that many occurrences.
+func (file *File) FindForward(target rune, count int, till bool) (xPosition int, found bool) {
+	offset := file.runeOffset
+	for count > 0 {
+		offset++
+		if file.isOutOfBounds(offset) {
+			return file.spacingOffset, false
+		}
+		if file.Current.Data[offset] == target {
+			count--
+		}
+	}
+	if till {
+		offset--
+	}
+	file.runeOffset = offset
+	return file.setSpacingOffset(), true
 }
 
-func (file *File) isOutOfBounds(index int) bool {
-	return index < 0 || index >= len(file.Current.Data)
+// FindBackward will move the cursor to the count'th occurrence of the
+// rune before the cursor on the current line, or just after it if till
+// is true. The cursor does not move if there are not that many occurrences.
+func (file *File) FindBackward(target rune, count int, till bool) (xPosition int, found bool) {
+	offset := file.runeOffset
+	for count > 0 {
+		offset--
+		if file.isOutOfBounds(offset) {
+			return file.spacingOffset, false
+		}
+		if file.Current.Data[offset] == target {
+			count--
+		}
+	}
+	if till {
+		offset++
+	}
+	file.runeOffset = offset
+	return file.setSpacingOffset(), true
 }
 
-func (file *File) moveLineDown(linesDown int) int {
-	file.spacingOffset = 0
-	file.runeOffset = 0
-	file.Current = file.Current.Next
-	return linesDown + 1
+// moveTo moves the cursor to the position which a motion found,
+// keeping it on a rune, and returns how many lines it moved.
+func (file *File) moveTo(position Position) (xPosition int, lines int) {
+	forward := file.Compare(file.Cursor(), position) <= 0
+	for line := file.Current; line != position.Line; lines++ {
+		if forward {
+			line = line.Next
+		} else {
+			line = line.Prev
+		}
+	}
+	file.Current = position.Line
+	file.runeOffset = position.Offset
+	return file.setBoundedOffsets(), lines
 }
 
-func (file *File) moveLineUp(linesUp int) int {
-	file.Current = file.Current.Prev
-	file.runeOffset = len(file.Current.Data) - 1
-	return linesUp + 1
+func (file *File) isOutOfBounds(index int) bool {
+	return index < 0 || index >= len(file.Current.Data)
 }
 
 func (file *File) setBoundedOffsets() (spacingOffset int) {
@@ -172,3 +172,168 @@ func TestNextWordEnd(t *testing.T) {
 		t.Error("did not go to next word end")
 	}
 }
+
+func TestFindForward(t *testing.T) {
+	file := fileWithText("a\tb,c,d")
+	x, found := file.FindForward(',', 1, false)
+	if !found || x != 9 {
+		t.Errorf("bad find: %d", x)
+	}
+	x, found = file.FindForward(',', 1, true)
+	if !found || x != 10 {
+		t.Errorf("bad till: %d", x)
+	}
+	file.StartOfLine()
+	x, found = file.FindForward(',', 2, false)
+	if !found || x != 11 {
+		t.Errorf("bad counted find: %d", x)
+	}
+	x, found = file.FindForward(',', 1, false)
+	if found || x != 11 {
+		t.Error("should not have moved")
+	}
+}
+
+func TestFindBackward(t *testing.T) {
+	file := fileWithText("a,b\t,c")
+	file.EndOfLine(false)
+	x, found := file.FindBackward(',', 1, false)
+	if !found || x != 8 {
+		t.Errorf("bad find: %d", x)
+	}
+	file.EndOfLine(false)
+	x, found = file.FindBackward(',', 2, true)
+	if !found || x != 2 {
+		t.Errorf("bad till: %d", x)
+	}
+	x, found = file.FindBackward('z', 1, false)
+	if found || x != 2 {
+		t.Error("should not have moved")
+	}
+}
+
+func TestSetCursor(t *testing.T) {
+	file := fileWithText("a\tb\nxyz")
+	x := file.SetCursor(Position{Line: file.First, Offset: 2}, false)
+	if x != 8 {
+		t.Errorf("bad position: %d", x)
+	}
+	x = file.SetCursor(Position{Line: file.First.Next, Offset: 5}, false)
+	if x != 2 || file.Cursor().Offset != 2 {
+		t.Error("cursor should stay on the last rune")
+	}
+	x = file.SetCursor(Position{Line: file.First.Next, Offset: 5}, true)
+	if x != 3 || file.Cursor().Offset != 3 {
+		t.Error("cursor should be after the last rune")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	file := fileWithText("abc\ndef")
+	a := Position{Line: file.First, Offset: 2}
+	b := Position{Line: file.First.Next, Offset: 0}
+	if file.Compare(a, b) >= 0 || file.Compare(b, a) <= 0 || file.Compare(a, a) != 0 {
+		t.Error("bad comparison")
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	file := fileWithText("a\nb\nc")
+	if file.LineNumber(file.last) != 3 || file.LineNumber(file.First) != 1 {
+		t.Error("bad line number")
+	}
+	if file.LineAt(2) != file.First.Next || file.LineAt(10) != file.last {
+		t.Error("bad line")
+	}
+}
+
+func TestLineNumbersAfterEdits(t *testing.T) {
+	file := fileWithText("a\nb\nc\nd\ne\nf\ng")
+	checkNumbers := func(edit string) {
+		number := 1
+		for line := file.First; line != nil; line = line.Next {
+			if file.LineNumber(line) != number {
+				t.Errorf("after %s, line %d is numbered %d", edit, number, file.LineNumber(line))
+			}
+			if file.LineAt(number) != line {
+				t.Errorf("after %s, line %d is not found by its number", edit, number)
+			}
+			number++
+		}
+	}
+	file.LineNumber(file.last)
+	file.SetCursor(Position{Line: file.LineAt(3), Offset: 1}, true)
+	file.Add('\n')
+	checkNumbers("splitting a line")
+	file.Add('\n')
+	checkNumbers("adding a line above")
+	file.Backspace()
+	checkNumbers("joining with backspace")
+	file.RemoveLine(false)
+	checkNumbers("removing a line")
+	file.JumpToTop()
+	file.RemoveLine(false)
+	checkNumbers("removing the first line")
+	file.JoinLines(file.LineAt(2), 3, false)
+	checkNumbers("joining lines")
+	file.InsertLines([]rune("x\ny\n"), true)
+	checkNumbers("putting lines below")
+	file.InsertLines([]rune("z\n"), false)
+	checkNumbers("putting a line above")
+	file.Delete(Position{Line: file.LineAt(2)}, Position{Line: file.LineAt(4)}, false)
+	checkNumbers("deleting across lines")
+	file.DeleteLines(file.LineAt(3), file.last)
+	checkNumbers("deleting the last lines")
+	file.DeleteLines(file.First, file.last)
+	checkNumbers("deleting every line")
+}
+
+func TestParagraph(t *testing.T) {
+	file := fileWithText("a\nb\n\n\nc\n\nd")
+	_, lines := file.NextParagraph()
+	if lines != 2 || file.LineNumber(file.Current) != 3 {
+		t.Error("did not go to the end of the paragraph")
+	}
+	_, lines = file.NextParagraph()
+	if lines != 3 || file.LineNumber(file.Current) != 6 {
+		t.Error("did not skip the empty lines")
+	}
+	file.NextParagraph()
+	if file.Current != file.last {
+		t.Error("did not go to the end of the file")
+	}
+	_, lines = file.PrevParagraph()
+	if lines != 1 || file.LineNumber(file.Current) != 6 {
+		t.Error("did not go to the start of the paragraph")
+	}
+	file.PrevParagraph()
+	file.PrevParagraph()
+	if file.Current != file.First {
+		t.Error("did not go to the start of the file")
+	}
+}
+
+func TestSentence(t *testing.T) {
+	file := fileWithText("One two.  Three\nfour!\n\nFive")
+	file.NextSentence()
+	if file.Cursor().Offset != 10 {
+		t.Errorf("did not go to the next sentence: %d", file.Cursor().Offset)
+	}
+	_, lines := file.NextSentence()
+	if lines != 2 || len(file.Current.Data) != 0 {
+		t.Error("an empty line should be a sentence")
+	}
+	file.NextSentence()
+	if file.Current != file.last || file.Cursor().Offset != 0 {
+		t.Error("did not go to the last sentence")
+	}
+	file.SetCursor(Position{Line: file.First.Next, Offset: 2}, false)
+	_, lines = file.PrevSentence()
+	if lines != 1 || file.Cursor().Offset != 10 {
+		t.Error("did not go to the start of the sentence")
+	}
+	file.PrevSentence()
+	if file.Cursor() != (Position{Line: file.First}) {
+		t.Error("did not go to the previous sentence")
+	}
+}
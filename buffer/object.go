@@ -0,0 +1,420 @@
+package buffer
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Range is the runes from Start up to, but not including, End. When
+// Linewise is true, the range covers every line from Start to End.
+type Range struct {
+	Start    Position
+	End      Position
+	Linewise bool
+}
+
+// span is a run of text which a text object is made of, such as a word
+// or the whitespace between two words.
+type span struct {
+	start int
+	end   int
+	blank bool
+}
+
+// selectSpans finds the span which contains the index, and selects count
+// spans from it. With around, each span is selected along with the span
+// after it if only one of them is blank, and if that leaves no trailing
+// whitespace, the whitespace before the spans is selected instead.
+func selectSpans(spans []span, index, count int, around bool) (start, end int, ok bool) {
+	first := -1
+	for i, s := range spans {
+		if index >= s.start && index < s.end {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return 0, 0, false
+	}
+	last := first - 1
+	for n := 0; n < count && last+1 < len(spans); n++ {
+		last++
+		if around && last+1 < len(spans) && spans[last+1].blank != spans[last].blank {
+			last++
+		}
+	}
+	if around && !spans[first].blank && !spans[last].blank && first > 0 && spans[first-1].blank {
+		first--
+	}
+	return spans[first].start, spans[last].end, true
+}
+
+// flatten returns every rune of the file, with a newline after each line,
+// along with the position of each of them.
+func (file *File) flatten() (text []rune, positions []Position) {
+	for line := file.First; line != nil; line = line.Next {
+		for i, r := range line.Data {
+			text = append(text, r)
+			positions = append(positions, Position{Line: line, Offset: i})
+		}
+		text = append(text, '\n')
+		positions = append(positions, Position{Line: line, Offset: len(line.Data)})
+	}
+	return text, positions
+}
+
+func indexOf(positions []Position, position Position) int {
+	for i, p := range positions {
+		if p == position {
+			return i
+		}
+	}
+	return -1
+}
+
+func positionAt(positions []Position, index int) Position {
+	if index >= len(positions) {
+		return positions[len(positions)-1]
+	}
+	return positions[index]
+}
+
+func flatRange(positions []Position, start, end int) Range {
+	return Range{Start: positionAt(positions, start), End: positionAt(positions, end)}
+}
+
+// WordObject is "iw" or "aw", or with bigWord, "iW" or "aW". It stays
+// within the current line.
+func (file *File) WordObject(count int, around, bigWord bool) (Range, bool) {
+	data := file.Current.Data
+	spans := make([]span, 0)
+	for i := 0; i < len(data); {
+		class := file.class(data[i], bigWord)
+		start := i
+		for i < len(data) && file.class(data[i], bigWord) == class {
+			i++
+		}
+		spans = append(spans, span{start: start, end: i, blank: class == blankClass})
+	}
+	start, end, ok := selectSpans(spans, file.runeOffset, count, around)
+	if !ok {
+		return Range{}, false
+	}
+	return Range{Start: Position{Line: file.Current, Offset: start}, End: Position{Line: file.Current, Offset: end}}, true
+}
+
+// SentenceObject is "is" or "as". A sentence ends at a ".", "!" or "?"
+// which is followed by the end of a line or whitespace, optionally with
+// closing brackets or quotes in between, and also ends at an empty line.
+func (file *File) SentenceObject(count int, around bool) (Range, bool) {
+	if len(file.Current.Data) == 0 {
+		return Range{}, false
+	}
+	text, positions := file.flatten()
+	index := indexOf(positions, file.Cursor())
+	first := index
+	for first > 0 && !(text[first-1] == '\n' && (first < 2 || text[first-2] == '\n')) {
+		first--
+	}
+	last := index
+	for last < len(text)-1 && !(text[last] == '\n' && text[last+1] == '\n') {
+		last++
+	}
+	spans := make([]span, 0)
+	for i := first; i < last; {
+		start := i
+		if unicode.IsSpace(text[i]) {
+			for i < last && unicode.IsSpace(text[i]) {
+				i++
+			}
+			spans = append(spans, span{start: start, end: i, blank: true})
+			continue
+		}
+		i = sentenceEnd(text, i, last)
+		spans = append(spans, span{start: start, end: i})
+	}
+	start, end, ok := selectSpans(spans, index, count, around)
+	if !ok {
+		return Range{}, false
+	}
+	return flatRange(positions, start, end), true
+}
+
+func sentenceEnd(text []rune, i, last int) int {
+	for ; i < last; i++ {
+		if text[i] != '.' && text[i] != '!' && text[i] != '?' {
+			continue
+		}
+		end := i + 1
+		for end < last && isSentenceCloser(text[end]) {
+			end++
+		}
+		if end == last || unicode.IsSpace(text[end]) {
+			return end
+		}
+	}
+	for last > i && unicode.IsSpace(text[last-1]) {
+		last--
+	}
+	return last
+}
+
+// sentenceStarts returns the index of the start of every sentence in the
+// flattened text, where each empty line is also a sentence.
+func sentenceStarts(text []rune) []int {
+	starts := make([]int, 0)
+	for i := 0; i < len(text); {
+		if text[i] == '\n' && (i == 0 || text[i-1] == '\n') {
+			starts = append(starts, i)
+			i++
+			continue
+		}
+		if unicode.IsSpace(text[i]) {
+			i++
+			continue
+		}
+		starts = append(starts, i)
+		last := i
+		for last < len(text)-1 && !(text[last] == '\n' && text[last+1] == '\n') {
+			last++
+		}
+		i = sentenceEnd(text, i, last)
+	}
+	return starts
+}
+
+func isSentenceCloser(r rune) bool {
+	return r == ')' || r == ']' || r == '"' || r == '\''
+}
+
+// ParagraphObject is "ip" or "ap". A paragraph is a run of lines which
+// are not blank, and the blank lines between paragraphs are selected as
+// a paragraph of their own.
+func (file *File) ParagraphObject(count int, around bool) (Range, bool) {
+	lines := make([]*Line, 0, file.Lines)
+	index := 0
+	for line := file.First; line != nil; line = line.Next {
+		if line == file.Current {
+			index = len(lines)
+		}
+		lines = append(lines, line)
+	}
+	spans := make([]span, 0)
+	for i := 0; i < len(lines); {
+		blank := lines[i].FirstNonBlank() == len(lines[i].Data)
+		start := i
+		for i < len(lines) && lines[i].FirstNonBlank() == len(lines[i].Data) == blank {
+			i++
+		}
+		spans = append(spans, span{start: start, end: i, blank: blank})
+	}
+	start, end, ok := selectSpans(spans, index, count, around)
+	if !ok {
+		return Range{}, false
+	}
+	last := lines[end-1]
+	return Range{Start: Position{Line: lines[start]}, End: Position{Line: last, Offset: len(last.Data)}, Linewise: true}, true
+}
+
+// QuoteObject is "i" or "a" followed by a quote character. Quotes are
+// paired from the start of the line, skipping quotes escaped with a
+// backslash, and if the cursor is not within a quoted string, the next
+// one on the line is used. With around, the quotes are selected along
+// with the whitespace after them, or before them if there is none after.
+// A count of two or more selects the quotes but no whitespace.
+func (file *File) QuoteObject(quote rune, count int, around bool) (Range, bool) {
+	data := file.Current.Data
+	quotes := make([]int, 0)
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\\' {
+			i++
+			continue
+		}
+		if data[i] == quote {
+			quotes = append(quotes, i)
+		}
+	}
+	open, close := -1, -1
+	for i := 0; i+1 < len(quotes); i += 2 {
+		if quotes[i+1] >= file.runeOffset {
+			open, close = quotes[i], quotes[i+1]
+			break
+		}
+	}
+	if open < 0 {
+		return Range{}, false
+	}
+	start, end := open+1, close
+	if around || count > 1 {
+		start, end = open, close+1
+	}
+	if around && count <= 1 {
+		trailing := end
+		for trailing < len(data) && unicode.IsSpace(data[trailing]) {
+			trailing++
+		}
+		if trailing > end {
+			end = trailing
+		} else {
+			for start > 0 && unicode.IsSpace(data[start-1]) {
+				start--
+			}
+		}
+	}
+	return Range{Start: Position{Line: file.Current, Offset: start}, End: Position{Line: file.Current, Offset: end}}, true
+}
+
+// BracketObject is "i" or "a" followed by a bracket, which selects the
+// count'th pair of brackets around the cursor. When the opening bracket
+// ends its line and the closing bracket starts its line, the inside is
+// the whole lines between them.
+func (file *File) BracketObject(open, close rune, count int, around bool) (Range, bool) {
+	text, positions := file.flatten()
+	index := indexOf(positions, file.Cursor())
+	i := index
+	if text[i] == close {
+		i--
+	}
+	openIndex := -1
+	for found := 0; found < count; found++ {
+		depth := 0
+		for ; i >= 0; i-- {
+			if text[i] == close {
+				depth++
+			} else if text[i] == open {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		if i < 0 {
+			return Range{}, false
+		}
+		openIndex = i
+		i--
+	}
+	closeIndex := matchForward(text, openIndex, open, close)
+	if closeIndex < 0 {
+		return Range{}, false
+	}
+	if around {
+		return flatRange(positions, openIndex, closeIndex+1), true
+	}
+	openLine := positions[openIndex].Line
+	closeLine := positions[closeIndex].Line
+	if text[openIndex+1] == '\n' && openLine != closeLine && positions[closeIndex].Offset <= closeLine.FirstNonBlank() {
+		if openLine.Next == closeLine {
+			return Range{}, false
+		}
+		last := closeLine.Prev
+		return Range{Start: Position{Line: openLine.Next}, End: Position{Line: last, Offset: len(last.Data)}, Linewise: true}, true
+	}
+	return flatRange(positions, openIndex+1, closeIndex), true
+}
+
+func matchForward(text []rune, openIndex int, open, close rune) int {
+	depth := 0
+	for i := openIndex + 1; i < len(text); i++ {
+		if text[i] == open {
+			depth++
+		} else if text[i] == close {
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+type tag struct {
+	start   int
+	end     int
+	name    string
+	closing bool
+}
+
+// TagObject is "it" or "at", which selects the count'th pair of XML or
+// HTML tags around the cursor, either between the tags or including them.
+func (file *File) TagObject(count int, around bool) (Range, bool) {
+	text, positions := file.flatten()
+	index := indexOf(positions, file.Cursor())
+	type pair struct{ open, close tag }
+	pairs := make([]pair, 0)
+	stack := make([]tag, 0)
+	for _, t := range parseTags(text) {
+		if !t.closing {
+			stack = append(stack, t)
+			continue
+		}
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].name == t.name {
+				pairs = append(pairs, pair{open: stack[i], close: t})
+				stack = stack[:i]
+				break
+			}
+		}
+	}
+	enclosing := make([]pair, 0)
+	for _, p := range pairs {
+		if p.open.start <= index && index < p.close.end {
+			enclosing = append(enclosing, p)
+		}
+	}
+	if count > len(enclosing) {
+		return Range{}, false
+	}
+	sort.Slice(enclosing, func(i, j int) bool {
+		return enclosing[i].close.end-enclosing[i].open.start < enclosing[j].close.end-enclosing[j].open.start
+	})
+	selected := enclosing[count-1]
+	if around {
+		return flatRange(positions, selected.open.start, selected.close.end), true
+	}
+	return flatRange(positions, selected.open.end, selected.close.start), true
+}
+
+// parseTags finds the opening and closing tags in the text, leaving out
+// self-closing tags, comments and declarations.
+func parseTags(text []rune) []tag {
+	tags := make([]tag, 0)
+	for i := 0; i < len(text); i++ {
+		if text[i] != '<' {
+			continue
+		}
+		t := tag{start: i}
+		nameStart := i + 1
+		if nameStart < len(text) && text[nameStart] == '/' {
+			t.closing = true
+			nameStart++
+		}
+		nameEnd := nameStart
+		for nameEnd < len(text) && isTagNameRune(text[nameEnd]) {
+			nameEnd++
+		}
+		if nameEnd == nameStart || !unicode.IsLetter(text[nameStart]) {
+			continue
+		}
+		end := nameEnd
+		for end < len(text) && text[end] != '>' && text[end] != '<' {
+			end++
+		}
+		if end == len(text) || text[end] != '>' {
+			continue
+		}
+		if text[end-1] == '/' {
+			i = end
+			continue
+		}
+		t.name = string(text[nameStart:nameEnd])
+		t.end = end + 1
+		tags = append(tags, t)
+		i = end
+	}
+	return tags
+}
+
+func isTagNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == ':' || r == '.' || r == '_'
+}
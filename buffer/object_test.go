@@ -0,0 +1,119 @@
+package buffer
+
+import "testing"
+
+func rangeText(file *File, r Range) string {
+	if r.Linewise {
+		return string(file.LinesText(r.Start.Line, r.End.Line))
+	}
+	return string(file.Text(r.Start, r.End))
+}
+
+func checkObject(t *testing.T, file *File, r Range, ok bool, expected string) {
+	t.Helper()
+	if !ok {
+		t.Errorf("expected %q but found nothing", expected)
+		return
+	}
+	if text := rangeText(file, r); text != expected {
+		t.Errorf("expected %q but got %q", expected, text)
+	}
+}
+
+func TestWordObject(t *testing.T) {
+	file := fileWithText("call foo.bar  baz")
+	file.SetCursor(Position{Line: file.Current, Offset: 6}, false)
+	r, ok := file.WordObject(1, false, false)
+	checkObject(t, file, r, ok, "foo")
+	r, ok = file.WordObject(3, false, false)
+	checkObject(t, file, r, ok, "foo.bar")
+	r, ok = file.WordObject(1, true, true)
+	checkObject(t, file, r, ok, "foo.bar  ")
+	file.SetCursor(Position{Line: file.Current, Offset: 15}, false)
+	r, ok = file.WordObject(1, true, false)
+	checkObject(t, file, r, ok, "  baz")
+}
+
+func TestSentenceObject(t *testing.T) {
+	file := fileWithText("One two. Three\nfour! Five.\n\nSix.")
+	file.SetCursor(Position{Line: file.First, Offset: 10}, false)
+	r, ok := file.SentenceObject(1, false)
+	checkObject(t, file, r, ok, "Three\nfour!")
+	r, ok = file.SentenceObject(1, true)
+	checkObject(t, file, r, ok, "Three\nfour! ")
+	file.SetCursor(Position{Line: file.First.Next, Offset: 8}, false)
+	r, ok = file.SentenceObject(1, true)
+	checkObject(t, file, r, ok, " Five.")
+}
+
+func TestParagraphObject(t *testing.T) {
+	file := fileWithText("a\nb\n\n\nc\nd")
+	r, ok := file.ParagraphObject(1, false)
+	checkObject(t, file, r, ok, "a\nb\n")
+	r, ok = file.ParagraphObject(1, true)
+	checkObject(t, file, r, ok, "a\nb\n\n\n")
+	file.SetCursor(Position{Line: file.LineAt(6)}, false)
+	r, ok = file.ParagraphObject(1, true)
+	checkObject(t, file, r, ok, "\n\nc\nd\n")
+}
+
+func TestQuoteObject(t *testing.T) {
+	file := fileWithText(`x := "a \"b\"" + "c"`)
+	file.SetCursor(Position{Line: file.Current, Offset: 8}, false)
+	r, ok := file.QuoteObject('"', 1, false)
+	checkObject(t, file, r, ok, `a \"b\"`)
+	file.StartOfLine()
+	r, ok = file.QuoteObject('"', 1, true)
+	checkObject(t, file, r, ok, `"a \"b\"" `)
+	file.SetCursor(Position{Line: file.Current, Offset: 15}, false)
+	r, ok = file.QuoteObject('"', 1, true)
+	checkObject(t, file, r, ok, ` "c"`)
+	r, ok = file.QuoteObject('\'', 1, false)
+	if ok {
+		t.Error("there are no single quotes")
+	}
+}
+
+func TestBracketObject(t *testing.T) {
+	file := fileWithText("f(a, g(b), c)")
+	file.SetCursor(Position{Line: file.Current, Offset: 7}, false)
+	r, ok := file.BracketObject('(', ')', 1, false)
+	checkObject(t, file, r, ok, "b")
+	r, ok = file.BracketObject('(', ')', 2, false)
+	checkObject(t, file, r, ok, "a, g(b), c")
+	file.SetCursor(Position{Line: file.Current, Offset: 8}, false)
+	r, ok = file.BracketObject('(', ')', 1, true)
+	checkObject(t, file, r, ok, "(b)")
+	file.StartOfLine()
+	_, ok = file.BracketObject('(', ')', 1, false)
+	if ok {
+		t.Error("cursor is not within brackets")
+	}
+}
+
+func TestBracketObjectLines(t *testing.T) {
+	file := fileWithText("x := {\n\t\"a\": 1,\n\t\"b\": 2,\n}")
+	file.SetCursor(Position{Line: file.LineAt(2), Offset: 2}, false)
+	r, ok := file.BracketObject('{', '}', 1, false)
+	checkObject(t, file, r, ok, "\t\"a\": 1,\n\t\"b\": 2,\n")
+	if !r.Linewise {
+		t.Error("should be linewise")
+	}
+	r, ok = file.BracketObject('{', '}', 1, true)
+	checkObject(t, file, r, ok, "{\n\t\"a\": 1,\n\t\"b\": 2,\n}")
+}
+
+func TestTagObject(t *testing.T) {
+	file := fileWithText("<div class=\"x\"><p>hi <br/>there</p></div>")
+	file.SetCursor(Position{Line: file.Current, Offset: 19}, false)
+	r, ok := file.TagObject(1, false)
+	checkObject(t, file, r, ok, "hi <br/>there")
+	r, ok = file.TagObject(2, false)
+	checkObject(t, file, r, ok, "<p>hi <br/>there</p>")
+	r, ok = file.TagObject(1, true)
+	checkObject(t, file, r, ok, "<p>hi <br/>there</p>")
+	_, ok = file.TagObject(3, true)
+	if ok {
+		t.Error("there are only two tags")
+	}
+}
@@ -0,0 +1,141 @@
+package buffer
+
+import (
+	"strconv"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// fillColumns returns the whitespace which goes from one column to
+// another, using tabs of the width where they fit unless expandtab is set.
+func (file *File) fillColumns(from, to, tabSize int) []rune {
+	text := make([]rune, 0, to-from)
+	if !file.Options.Bool("expandtab") {
+		for next := (from/tabSize + 1) * tabSize; next <= to; next += tabSize {
+			text = append(text, '\t')
+			from = next
+		}
+	}
+	for ; from < to; from++ {
+		text = append(text, ' ')
+	}
+	return text
+}
+
+// softTabStop is how many columns tab and backspace move in insert mode,
+// which is the shiftwidth when softtabstop is negative, or zero to insert
+// and remove real tabs.
+func (file *File) softTabStop() int {
+	stop := file.Options.Number("softtabstop")
+	if stop < 0 {
+		return file.ShiftWidth()
+	}
+	return stop
+}
+
+// blankStart returns the offset where the whitespace before the cursor
+// starts, and its column.
+func (file *File) blankStart() (offset, column int) {
+	data := file.Current.Data
+	offset = file.runeOffset
+	for offset > 0 && (data[offset-1] == ' ' || data[offset-1] == '\t') {
+		offset--
+	}
+	for _, r := range data[:offset] {
+		column = RuneWidthJump(r, column, file.TabStop())
+	}
+	return offset, column
+}
+
+// replaceBlanks replaces the whitespace from the offset up to the cursor
+// with whitespace which reaches the column, and leaves the cursor after it.
+func (file *File) replaceBlanks(offset, column, to int) {
+	fill := file.fillColumns(column, to, file.TabStop())
+	data := append(append([]rune{}, file.Current.Data[:offset]...), fill...)
+	file.Current.Data = append(data, file.Current.Data[file.runeOffset:]...)
+	file.SetCursor(Position{Line: file.Current, Offset: offset + len(fill)}, true)
+	file.recordChange(file.Cursor())
+}
+
+// typeTab is tab in insert mode, which moves to the next multiple of
+// softtabstop, or of tabstop with expandtab, filling with spaces and tabs.
+func (file *File) typeTab() {
+	stop := file.softTabStop()
+	if stop == 0 {
+		if !file.Options.Bool("expandtab") {
+			file.Add('\t')
+			return
+		}
+		stop = file.TabStop()
+	}
+	offset, column := file.blankStart()
+	to := (file.spacingOffset/stop + 1) * stop
+	file.replaceBlanks(offset, column, to)
+}
+
+// Erase is backspace in insert mode. When softtabstop is set and there is
+// only whitespace before the cursor back to the previous multiple of it,
+// the whitespace back to there is removed, and otherwise a rune is.
+func (file *File) Erase() (xPosition int, deletedLine bool) {
+	stop := file.softTabStop()
+	if stop == 0 || file.runeOffset == 0 {
+		return file.Backspace()
+	}
+	previous := file.Current.Data[file.runeOffset-1]
+	if previous != ' ' && previous != '\t' {
+		return file.Backspace()
+	}
+	offset, column := file.blankStart()
+	to := (file.spacingOffset - 1) / stop * stop
+	if to < column {
+		to = column
+	}
+	file.replaceBlanks(offset, column, to)
+	return file.spacingOffset, false
+}
+
+// Retab changes the whitespace in every line from first to last, inclusive,
+// which has a tab in it, so that it looks the same with the new tabstop,
+// using spaces if expandtab is set. When all is true, whitespace which is
+// only spaces is changed too. The tabstop of the buffer is then set to the
+// new one.
+func (file *File) Retab(first, last *Line, tabSize int, all bool) {
+	old := file.TabStop()
+	for line := first; line != nil; line = line.Next {
+		data := make([]rune, 0, len(line.Data))
+		changed := false
+		column := 0
+		for i := 0; i < len(line.Data); {
+			if line.Data[i] != ' ' && line.Data[i] != '\t' {
+				data = append(data, line.Data[i])
+				column += runewidth.RuneWidth(line.Data[i])
+				i++
+				continue
+			}
+			start, end, hasTab := column, column, false
+			j := i
+			for ; j < len(line.Data) && (line.Data[j] == ' ' || line.Data[j] == '\t'); j++ {
+				end = RuneWidthJump(line.Data[j], end, old)
+				hasTab = hasTab || line.Data[j] == '\t'
+			}
+			if hasTab || all {
+				fill := file.fillColumns(start, end, tabSize)
+				changed = changed || string(fill) != string(line.Data[i:j])
+				data = append(data, fill...)
+			} else {
+				data = append(data, line.Data[i:j]...)
+			}
+			column = end
+			i = j
+		}
+		if changed {
+			line.Data = data
+			file.recordChange(Position{Line: line})
+		}
+		if line == last {
+			break
+		}
+	}
+	_, _ = file.Options.SetLocal("tabstop=" + strconv.Itoa(tabSize))
+	file.SetCursor(file.Cursor(), false)
+}
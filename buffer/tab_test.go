@@ -0,0 +1,70 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/bkthomps/Ven/option"
+)
+
+func TestTabStop(t *testing.T) {
+	file := fileWithText("\tab")
+	_, _ = file.Options.SetLocal("tabstop=4")
+	file.EndOfLine(false)
+	if file.XPosition() != 5 {
+		t.Errorf("tab should be as wide as tabstop: %d", file.XPosition())
+	}
+}
+
+func TestSoftTabStop(t *testing.T) {
+	file := fileWithText("x")
+	_, _ = file.Options.Set("softtabstop=4 expandtab")
+	file.EndOfLine(true)
+	typeText(file, "\t\t")
+	if fileText(file) != "x       \n" {
+		t.Errorf("bad soft tab: %q", fileText(file))
+	}
+	file.Erase()
+	if fileText(file) != "x   \n" {
+		t.Errorf("backspace should remove a soft tab: %q", fileText(file))
+	}
+	_, _ = file.Options.Set("noexpandtab")
+	typeText(file, "\t\t")
+	if fileText(file) != "x\t    \n" {
+		t.Errorf("soft tabs should use tabs where they fit: %q", fileText(file))
+	}
+	file.Erase()
+	file.Erase()
+	if fileText(file) != "x   \n" {
+		t.Errorf("backspace should go back to the previous soft tab: %q", fileText(file))
+	}
+	file.Erase()
+	file.Erase()
+	if fileText(file) != "\n" {
+		t.Errorf("backspace should remove a rune after whitespace: %q", fileText(file))
+	}
+}
+
+func TestRetab(t *testing.T) {
+	global := &option.Options{}
+	global.Init(nil)
+	file := &File{Options: &option.Options{}}
+	file.Options.Init(global)
+	file.Init("")
+	file.InsertText([]rune("\tab\n        cd\nx\ty  z"))
+	_, _ = file.Options.Set("expandtab")
+	file.Retab(file.First, file.last, 8, false)
+	if fileText(file) != "        ab\n        cd\nx       y  z\n" {
+		t.Errorf("bad retab: %q", fileText(file))
+	}
+	_, _ = file.Options.Set("noexpandtab")
+	file.Retab(file.First, file.last, 4, true)
+	if fileText(file) != "\t\tab\n\t\tcd\nx\t\ty  z\n" {
+		t.Errorf("bad retab with bang: %q", fileText(file))
+	}
+	if file.TabStop() != 4 {
+		t.Error("retab should set the tabstop")
+	}
+	if global.Number("tabstop") != 8 {
+		t.Error("retab should not set the global tabstop")
+	}
+}
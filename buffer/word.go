@@ -0,0 +1,215 @@
+package buffer
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The classes of runes which words are made of. A word is a run of
+// keyword runes or a run of punctuation, while a WORD is any run of
+// runes which are not blank.
+const (
+	blankClass = iota
+	punctuationClass
+	keywordClass
+)
+
+// keywords is the parsed iskeyword option, which is a comma separated
+// list where "@" stands for every letter, "a-z" or "48-57" is a range,
+// "@-@" is the "@" character, and a leading "^" removes the characters.
+// Runes above 255 are keywords unless they are punctuation or symbols.
+type keywords struct {
+	option string
+	table  [256]bool
+}
+
+func parseKeywords(option string) keywords {
+	parsed := keywords{option: option}
+	for _, item := range strings.Split(option, ",") {
+		include := true
+		if len(item) > 1 && item[0] == '^' {
+			include = false
+			item = item[1:]
+		}
+		if item == "@" {
+			for c := 0; c < len(parsed.table); c++ {
+				if unicode.IsLetter(rune(c)) {
+					parsed.table[c] = include
+				}
+			}
+			continue
+		}
+		low, high, ok := parseKeywordRange(item)
+		if !ok {
+			continue
+		}
+		for c := low; c <= high && c < len(parsed.table); c++ {
+			parsed.table[c] = include
+		}
+	}
+	return parsed
+}
+
+func parseKeywordRange(item string) (low, high int, ok bool) {
+	if item == "" {
+		return 0, 0, false
+	}
+	_, size := utf8.DecodeRuneInString(item)
+	separator := strings.IndexByte(item[size:], '-')
+	if separator < 0 || size+separator == len(item)-1 {
+		low, ok = parseKeywordCharacter(item)
+		return low, low, ok
+	}
+	separator += size
+	low, lowOk := parseKeywordCharacter(item[:separator])
+	high, highOk := parseKeywordCharacter(item[separator+1:])
+	return low, high, lowOk && highOk
+}
+
+func parseKeywordCharacter(text string) (int, bool) {
+	if number, err := strconv.Atoi(text); err == nil {
+		return number, true
+	}
+	if utf8.RuneCountInString(text) == 1 {
+		r, _ := utf8.DecodeRuneInString(text)
+		return int(r), true
+	}
+	return 0, false
+}
+
+func (file *File) class(r rune, bigWord bool) int {
+	if unicode.IsSpace(r) {
+		return blankClass
+	}
+	if bigWord {
+		return punctuationClass
+	}
+	if r < 256 {
+		option := file.Options.String("iskeyword")
+		if file.keywords.option != option {
+			file.keywords = parseKeywords(option)
+		}
+		if file.keywords.table[r] {
+			return keywordClass
+		}
+		return punctuationClass
+	}
+	if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+		return punctuationClass
+	}
+	return keywordClass
+}
+
+// Class returns the class of the rune at the position, where the end of
+// a line is blank. With bigWord, every rune which is not blank is of the
+// same class.
+func (file *File) Class(position Position, bigWord bool) int {
+	if position.Offset < 0 || position.Offset >= len(position.Line.Data) {
+		return blankClass
+	}
+	return file.class(position.Line.Data[position.Offset], bigWord)
+}
+
+// IsBlank reports whether the position is on a blank or the end of a line.
+func (file *File) IsBlank(position Position) bool {
+	return file.Class(position, false) == blankClass
+}
+
+// stepForward moves the position to the next rune, where the end of each
+// line is a position of its own, and reports whether it could move.
+func stepForward(position *Position) bool {
+	if position.Offset < len(position.Line.Data) {
+		position.Offset++
+		return true
+	}
+	if position.Line.Next == nil {
+		return false
+	}
+	*position = Position{Line: position.Line.Next}
+	return true
+}
+
+func stepBackward(position *Position) bool {
+	if position.Offset > 0 {
+		position.Offset--
+		return true
+	}
+	if position.Line.Prev == nil {
+		return false
+	}
+	*position = Position{Line: position.Line.Prev, Offset: len(position.Line.Prev.Data)}
+	return true
+}
+
+func isEmptyLine(position Position) bool {
+	return len(position.Line.Data) == 0
+}
+
+func (file *File) nextWordStart(bigWord bool) Position {
+	position := file.Cursor()
+	start := file.Class(position, bigWord)
+	if !stepForward(&position) {
+		return position
+	}
+	if start != blankClass {
+		for file.Class(position, bigWord) == start {
+			if !stepForward(&position) {
+				return position
+			}
+		}
+	}
+	for file.Class(position, bigWord) == blankClass && !isEmptyLine(position) {
+		if !stepForward(&position) {
+			return position
+		}
+	}
+	return position
+}
+
+func (file *File) prevWordStart(bigWord bool) Position {
+	position := file.Cursor()
+	if !stepBackward(&position) {
+		return position
+	}
+	for file.Class(position, bigWord) == blankClass {
+		if isEmptyLine(position) {
+			return position
+		}
+		if !stepBackward(&position) {
+			return position
+		}
+	}
+	class := file.Class(position, bigWord)
+	for file.Class(position, bigWord) == class {
+		if !stepBackward(&position) {
+			return position
+		}
+	}
+	stepForward(&position)
+	return position
+}
+
+func (file *File) nextWordEnd(bigWord bool) Position {
+	position := file.Cursor()
+	start := file.Class(position, bigWord)
+	if !stepForward(&position) {
+		return position
+	}
+	if file.Class(position, bigWord) != start || start == blankClass {
+		for file.Class(position, bigWord) == blankClass {
+			if !stepForward(&position) {
+				return position
+			}
+		}
+	}
+	class := file.Class(position, bigWord)
+	for file.Class(position, bigWord) == class {
+		if !stepForward(&position) {
+			return position
+		}
+	}
+	stepBackward(&position)
+	return position
+}
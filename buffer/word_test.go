@@ -0,0 +1,96 @@
+package buffer
+
+import "testing"
+
+func TestWordClasses(t *testing.T) {
+	file := fileWithText("foo.bar(baz) x")
+	expected := []int{0, 3, 4, 7, 8, 11, 13}
+	for _, x := range expected[1:] {
+		moved, _ := file.NextWordStart()
+		if moved != x {
+			t.Errorf("expected %d but got %d", x, moved)
+		}
+	}
+	for i := len(expected) - 2; i >= 0; i-- {
+		moved, _ := file.PrevWordStart()
+		if moved != expected[i] {
+			t.Errorf("expected %d but got %d", expected[i], moved)
+		}
+	}
+}
+
+func TestNextWordEndClasses(t *testing.T) {
+	file := fileWithText("foo.bar(baz)")
+	expected := []int{2, 3, 6, 7, 10, 11}
+	for _, x := range expected {
+		moved, _ := file.NextWordEnd()
+		if moved != x {
+			t.Errorf("expected %d but got %d", x, moved)
+		}
+	}
+}
+
+func TestBigWords(t *testing.T) {
+	file := fileWithText("foo.bar(baz) x,y z")
+	x, _ := file.NextBigWordStart()
+	if x != 13 {
+		t.Errorf("bad WORD start: %d", x)
+	}
+	x, _ = file.NextBigWordEnd()
+	if x != 15 {
+		t.Errorf("bad WORD end: %d", x)
+	}
+	x, _ = file.PrevBigWordStart()
+	if x != 13 {
+		t.Errorf("bad WORD start: %d", x)
+	}
+	x, _ = file.PrevBigWordStart()
+	if x != 0 {
+		t.Errorf("bad WORD start: %d", x)
+	}
+}
+
+func TestWordStopsAtEmptyLine(t *testing.T) {
+	file := fileWithText("foo\n\nbar")
+	_, lines := file.NextWordStart()
+	if lines != 1 || len(file.Current.Data) != 0 {
+		t.Error("should stop at the empty line")
+	}
+	_, lines = file.NextWordStart()
+	if lines != 1 || string(file.Current.Data) != "bar" {
+		t.Error("should move to the next word")
+	}
+	_, lines = file.PrevWordStart()
+	if lines != 1 || len(file.Current.Data) != 0 {
+		t.Error("should stop at the empty line")
+	}
+}
+
+func TestIskeyword(t *testing.T) {
+	file := fileWithText("foo-bar baz")
+	file.Options.Set("iskeyword+=-")
+	x, _ := file.NextWordStart()
+	if x != 8 {
+		t.Errorf("dash should be part of the word: %d", x)
+	}
+	file.Options.Set("iskeyword=a-z,^b")
+	file.StartOfLine()
+	x, _ = file.NextWordStart()
+	if x != 3 {
+		t.Errorf("dash should not be part of the word: %d", x)
+	}
+}
+
+func TestParseKeywords(t *testing.T) {
+	parsed := parseKeywords("@,48-57,_,^x,@-@,45")
+	for _, r := range "az_09@-Z" {
+		if !parsed.table[r] {
+			t.Errorf("%c should be a keyword", r)
+		}
+	}
+	for _, r := range "x.( " {
+		if parsed.table[r] {
+			t.Errorf("%c should not be a keyword", r)
+		}
+	}
+}
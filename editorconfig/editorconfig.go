@@ -0,0 +1,293 @@
+package editorconfig
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the files which hold the configuration. They
+// are looked for in the directory of the edited file and every directory
+// above it, until one of them sets root to true.
+const FileName = ".editorconfig"
+
+// known holds the properties whose values are not case-sensitive.
+var known = map[string]bool{
+	"indent_style":             true,
+	"indent_size":              true,
+	"tab_width":                true,
+	"end_of_line":              true,
+	"charset":                  true,
+	"trim_trailing_whitespace": true,
+	"insert_final_newline":     true,
+	"root":                     true,
+}
+
+type config struct {
+	root     bool
+	sections []section
+}
+
+type section struct {
+	glob       string
+	properties map[string]string
+}
+
+// parse reads the text of a configuration file. Properties before the
+// first section are only used for root.
+func parse(reader io.Reader) config {
+	parsed := config{}
+	var current *section
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			parsed.sections = append(parsed.sections, section{glob: line[1 : len(line)-1], properties: map[string]string{}})
+			current = &parsed.sections[len(parsed.sections)-1]
+			continue
+		}
+		index := strings.IndexAny(line, "=:")
+		if index < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:index]))
+		value := strings.TrimSpace(line[index+1:])
+		if known[key] {
+			value = strings.ToLower(value)
+		}
+		if current == nil {
+			if key == "root" {
+				parsed.root = value == "true"
+			}
+			continue
+		}
+		current.properties[key] = value
+	}
+	return parsed
+}
+
+// Properties returns the properties which apply to the file at the path.
+// Files closer to the edited file take precedence, as do later sections
+// within a file, and a property set to "unset" is removed.
+func Properties(path string) map[string]string {
+	properties := map[string]string{}
+	if path == "" {
+		return properties
+	}
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return properties
+	}
+	configs := make([]config, 0)
+	directories := make([]string, 0)
+	for directory := filepath.Dir(absolute); ; directory = filepath.Dir(directory) {
+		if osFile, err := os.Open(filepath.Join(directory, FileName)); err == nil {
+			parsed := parse(osFile)
+			_ = osFile.Close()
+			configs = append(configs, parsed)
+			directories = append(directories, directory)
+			if parsed.root {
+				break
+			}
+		}
+		if filepath.Dir(directory) == directory {
+			break
+		}
+	}
+	target := filepath.ToSlash(absolute)
+	for i := len(configs) - 1; i >= 0; i-- {
+		for _, s := range configs[i].sections {
+			if !Match(s.glob, filepath.ToSlash(directories[i]), target) {
+				continue
+			}
+			for key, value := range s.properties {
+				properties[key] = value
+			}
+		}
+	}
+	for key, value := range properties {
+		if value == "unset" {
+			delete(properties, key)
+		}
+	}
+	return properties
+}
+
+// Match reports whether the glob of a section, from a configuration file
+// in the directory, matches the path. A glob without a slash matches the
+// file name in any directory below the configuration file.
+func Match(glob, directory, path string) bool {
+	directory = strings.TrimSuffix(directory, "/")
+	expression, ranges := translate(glob)
+	if strings.Contains(glob, "/") {
+		expression = "^" + regexp.QuoteMeta(directory) + "/" + strings.TrimPrefix(expression, "/") + "$"
+	} else {
+		expression = "^" + regexp.QuoteMeta(directory) + "/(?:.*/)?" + expression + "$"
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return false
+	}
+	groups := re.FindStringSubmatch(path)
+	if groups == nil {
+		return false
+	}
+	for i, bounds := range ranges {
+		number, err := strconv.Atoi(groups[i+1])
+		if err != nil || number < bounds[0] || number > bounds[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// translate turns a glob into a regular expression. Each {low..high} is
+// a capture group, whose bounds are returned to be checked after a match.
+func translate(glob string) (expression string, ranges [][2]int) {
+	runes := []rune(glob)
+	var builder strings.Builder
+	braces := 0
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			builder.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				builder.WriteString(".*")
+				i++
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		case '/':
+			if strings.HasPrefix(string(runes[i:]), "/**/") {
+				builder.WriteString("(?:/|/.*/)")
+				i += 3
+			} else {
+				builder.WriteString("/")
+			}
+		case '[':
+			end := closingBracket(runes, i)
+			if end < 0 {
+				builder.WriteString(`\[`)
+				continue
+			}
+			builder.WriteString(characterClass(runes[i+1 : end]))
+			i = end
+		case '{':
+			if low, high, end, ok := numberRange(runes, i); ok {
+				builder.WriteString(`([+-]?\d+)`)
+				ranges = append(ranges, [2]int{low, high})
+				i = end
+			} else if hasAlternatives(runes, i) {
+				builder.WriteString("(?:")
+				braces++
+			} else {
+				builder.WriteString(`\{`)
+			}
+		case ',':
+			if braces > 0 {
+				builder.WriteString("|")
+			} else {
+				builder.WriteString(",")
+			}
+		case '}':
+			if braces > 0 {
+				builder.WriteString(")")
+				braces--
+			} else {
+				builder.WriteString(`\}`)
+			}
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return builder.String(), ranges
+}
+
+func closingBracket(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '/':
+			return -1
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func characterClass(inside []rune) string {
+	var builder strings.Builder
+	builder.WriteString("[")
+	if len(inside) > 0 && (inside[0] == '!' || inside[0] == '^') {
+		builder.WriteString("^")
+		inside = inside[1:]
+	}
+	for i := 0; i < len(inside); i++ {
+		r := inside[i]
+		if r == '\\' && i+1 < len(inside) {
+			i++
+			r = inside[i]
+		}
+		if strings.ContainsRune(`\[]^`, r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	builder.WriteString("]")
+	return builder.String()
+}
+
+var rangePattern = regexp.MustCompile(`^\{([+-]?\d+)\.\.([+-]?\d+)\}`)
+
+func numberRange(runes []rune, start int) (low, high, end int, ok bool) {
+	groups := rangePattern.FindStringSubmatch(string(runes[start:]))
+	if groups == nil {
+		return 0, 0, 0, false
+	}
+	low, _ = strconv.Atoi(groups[1])
+	high, _ = strconv.Atoi(groups[2])
+	if low > high {
+		low, high = high, low
+	}
+	return low, high, start + len([]rune(groups[0])) - 1, true
+}
+
+// hasAlternatives reports whether the brace at the start is closed and
+// has a comma directly inside it, otherwise the brace is literal.
+func hasAlternatives(runes []rune, start int) bool {
+	depth := 0
+	comma := false
+	for i := start; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				comma = true
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				return comma
+			}
+		}
+	}
+	return false
+}
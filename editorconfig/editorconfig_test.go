@@ -0,0 +1,84 @@
+package editorconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*", "/project/main.go", true},
+		{"*.go", "/project/deep/main.go", true},
+		{"*.go", "/project/main.py", false},
+		{"*.{js,py}", "/project/main.py", true},
+		{"*.{js,py}", "/project/main.go", false},
+		{"{a,b}.txt", "/project/c.txt", false},
+		{"Makefile", "/project/sub/Makefile", true},
+		{"/Makefile", "/project/sub/Makefile", false},
+		{"/Makefile", "/project/Makefile", true},
+		{"lib/*.go", "/project/lib/main.go", true},
+		{"lib/*.go", "/project/lib/sub/main.go", false},
+		{"lib/**.go", "/project/lib/sub/main.go", true},
+		{"a/**/b", "/project/a/b", true},
+		{"a/**/b", "/project/a/x/y/b", true},
+		{"file?.txt", "/project/file1.txt", true},
+		{"file[0-4].txt", "/project/file5.txt", false},
+		{"file[!0-4].txt", "/project/file5.txt", true},
+		{"file{1..10}.txt", "/project/file7.txt", true},
+		{"file{1..10}.txt", "/project/file11.txt", false},
+		{"\\*.txt", "/project/*.txt", true},
+		{"\\*.txt", "/project/a.txt", false},
+	}
+	for _, c := range cases {
+		if Match(c.glob, "/project", c.path) != c.match {
+			t.Errorf("bad match of %s against %s: expected %v", c.glob, c.path, c.match)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, path, text string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	directory := t.TempDir()
+	writeConfig(t, filepath.Join(directory, FileName), "root = true\n\n[*]\nindent_style = TAB\nindent_size = 8\n\n[*.go]\ncharset = utf-8\n")
+	writeConfig(t, filepath.Join(directory, "sub", FileName), "# comment\n[*.go]\nindent_size = 4\ncharset = unset\n")
+	properties := Properties(filepath.Join(directory, "sub", "main.go"))
+	if properties["indent_style"] != "tab" {
+		t.Errorf("bad indent_style: %q", properties["indent_style"])
+	}
+	if properties["indent_size"] != "4" {
+		t.Errorf("closer file should take precedence: %q", properties["indent_size"])
+	}
+	if _, ok := properties["charset"]; ok {
+		t.Error("unset property should be removed")
+	}
+	properties = Properties(filepath.Join(directory, "main.py"))
+	if properties["indent_size"] != "8" {
+		t.Errorf("bad indent_size: %q", properties["indent_size"])
+	}
+}
+
+func TestRoot(t *testing.T) {
+	directory := t.TempDir()
+	writeConfig(t, filepath.Join(directory, FileName), "[*]\nindent_style = space\ntab_width = 2\n")
+	writeConfig(t, filepath.Join(directory, "sub", FileName), "root = true\n[*]\ntab_width = 4\n")
+	properties := Properties(filepath.Join(directory, "sub", "main.go"))
+	if _, ok := properties["indent_style"]; ok {
+		t.Error("files above the root should not be read")
+	}
+	if properties["tab_width"] != "4" {
+		t.Errorf("bad tab_width: %q", properties["tab_width"])
+	}
+}
@@ -0,0 +1,171 @@
+package errorformat
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var emptyFormat = errors.New("empty errorformat")
+
+// fieldExpressions are what the fields of a format match.
+var fieldExpressions = map[rune]string{
+	'f': `(.+?)`,
+	'l': `(\d+)`,
+	'c': `(\d+)`,
+	'm': `(.*)`,
+	't': `(\pL)`,
+}
+
+// Entry is an error read from a line of output, where the line and the
+// column count from 1, and a column of 0 is unknown. The type is a letter
+// such as 'E' or 'W', or 0 when the format does not give one.
+type Entry struct {
+	File    string
+	Line    int
+	Column  int
+	Type    rune
+	Message string
+}
+
+// Formats are the formats of an errorformat, which are tried in order on
+// each line of output.
+type Formats []format
+
+type format struct {
+	re     *regexp.Regexp
+	fields []rune
+	ignore bool
+}
+
+// Compile reads an errorformat, which is a comma separated list of formats
+// where a comma in a format is written as "\,". In a format, %f is the
+// file name, %l the line, %c the column, %m the message, %t the type,
+// %. any character, %# any number of the character before it, and %% a
+// percent sign. A format which starts with %-G ignores the lines it
+// matches.
+func Compile(errorformat string) (Formats, error) {
+	formats := make(Formats, 0)
+	for _, text := range splitFormats(errorformat) {
+		if text == "" {
+			continue
+		}
+		compiled, err := compileFormat(text)
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, compiled)
+	}
+	if len(formats) == 0 {
+		return nil, emptyFormat
+	}
+	return formats, nil
+}
+
+func splitFormats(errorformat string) []string {
+	split := make([]string, 0)
+	var current strings.Builder
+	runes := []rune(errorformat)
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == ',':
+			current.WriteRune(',')
+			i++
+		case runes[i] == ',':
+			split = append(split, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(runes[i])
+		}
+	}
+	return append(split, current.String())
+}
+
+func compileFormat(text string) (format, error) {
+	var compiled format
+	if strings.HasPrefix(text, "%-G") {
+		compiled.ignore = true
+		text = text[len("%-G"):]
+	}
+	var expression strings.Builder
+	expression.WriteString("^")
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			expression.WriteString(regexp.QuoteMeta(string(runes[i])))
+			continue
+		}
+		i++
+		if i >= len(runes) {
+			return format{}, fmt.Errorf("trailing %% in errorformat: %s", text)
+		}
+		switch r := runes[i]; r {
+		case 'f', 'l', 'c', 'm', 't':
+			expression.WriteString(fieldExpressions[r])
+			compiled.fields = append(compiled.fields, r)
+		case '.':
+			expression.WriteString(`.`)
+		case '#':
+			expression.WriteString(`*`)
+		case '%':
+			expression.WriteString(`%`)
+		default:
+			return format{}, fmt.Errorf("unknown %%%c in errorformat: %s", r, text)
+		}
+	}
+	expression.WriteString("$")
+	re, err := regexp.Compile(expression.String())
+	if err != nil {
+		return format{}, fmt.Errorf("invalid errorformat: %s", text)
+	}
+	compiled.re = re
+	return compiled, nil
+}
+
+// Parse returns the errors in the output, in order. Lines which no format
+// matches, and those matched by a format which ignores them, are skipped.
+func (formats Formats) Parse(output string) []Entry {
+	entries := make([]Entry, 0)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if entry, ok := formats.parseLine(line); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (formats Formats) parseLine(line string) (Entry, bool) {
+	for _, compiled := range formats {
+		groups := compiled.re.FindStringSubmatch(line)
+		if groups == nil {
+			continue
+		}
+		if compiled.ignore {
+			return Entry{}, false
+		}
+		var entry Entry
+		for i, field := range compiled.fields {
+			value := groups[i+1]
+			switch field {
+			case 'f':
+				entry.File = value
+			case 'l':
+				entry.Line, _ = strconv.Atoi(value)
+			case 'c':
+				entry.Column, _ = strconv.Atoi(value)
+			case 'm':
+				entry.Message = value
+			case 't':
+				entry.Type = []rune(strings.ToUpper(value))[0]
+			}
+		}
+		if entry.File == "" {
+			continue
+		}
+		return entry, true
+	}
+	return Entry{}, false
+}
@@ -0,0 +1,52 @@
+package errorformat
+
+import "testing"
+
+func TestParseDefault(t *testing.T) {
+	formats, err := Compile("%f:%l:%c: %m,%f:%l: %m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := "# example.com/a\n./main.go:12:5: undefined: x\r\nlib/b.go:3: missing return\nok\n"
+	entries := formats.Parse(output)
+	expected := []Entry{
+		{File: "./main.go", Line: 12, Column: 5, Message: "undefined: x"},
+		{File: "lib/b.go", Line: 3, Message: "missing return"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %+v", len(expected), entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], entries[i])
+		}
+	}
+}
+
+func TestParseCustom(t *testing.T) {
+	formats, err := Compile(`%-G%.%#warning%.%#,%t:%f(%l\,%c) %m,100%%:%f`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := formats.Parse("e:a.c(4,2) bad\nw:b.c(1,1) a warning here\n100%:c.c\nw:b.c(x,1) bad")
+	expected := []Entry{
+		{File: "a.c", Line: 4, Column: 2, Type: 'E', Message: "bad"},
+		{File: "c.c"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %+v", len(expected), entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], entries[i])
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, errorformat := range []string{"", ",", "%f:%q", "%f:%"} {
+		if _, err := Compile(errorformat); err == nil {
+			t.Errorf("%q: expected an error", errorformat)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package keymap
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Key is a single key press, normalized so that keys which are typed
+// the same way compare as equal.
+type Key struct {
+	Code tcell.Key
+	Rune rune
+	Mod  tcell.ModMask
+}
+
+var namedKeys = map[string]Key{
+	"esc":      {Code: tcell.KeyEsc},
+	"cr":       {Code: tcell.KeyEnter},
+	"enter":    {Code: tcell.KeyEnter},
+	"return":   {Code: tcell.KeyEnter},
+	"tab":      {Code: tcell.KeyTab},
+	"bs":       {Code: tcell.KeyDEL},
+	"del":      {Code: tcell.KeyDelete},
+	"insert":   {Code: tcell.KeyInsert},
+	"up":       {Code: tcell.KeyUp},
+	"down":     {Code: tcell.KeyDown},
+	"left":     {Code: tcell.KeyLeft},
+	"right":    {Code: tcell.KeyRight},
+	"home":     {Code: tcell.KeyHome},
+	"end":      {Code: tcell.KeyEnd},
+	"pageup":   {Code: tcell.KeyPgUp},
+	"pagedown": {Code: tcell.KeyPgDn},
+	"space":    {Code: tcell.KeyRune, Rune: ' '},
+	"lt":       {Code: tcell.KeyRune, Rune: '<'},
+	"bar":      {Code: tcell.KeyRune, Rune: '|'},
+	"bslash":   {Code: tcell.KeyRune, Rune: '\\'},
+}
+
+var keyNames = map[tcell.Key]string{
+	tcell.KeyEsc:    "Esc",
+	tcell.KeyEnter:  "CR",
+	tcell.KeyTab:    "Tab",
+	tcell.KeyDEL:    "BS",
+	tcell.KeyDelete: "Del",
+	tcell.KeyInsert: "Insert",
+	tcell.KeyUp:     "Up",
+	tcell.KeyDown:   "Down",
+	tcell.KeyLeft:   "Left",
+	tcell.KeyRight:  "Right",
+	tcell.KeyHome:   "Home",
+	tcell.KeyEnd:    "End",
+	tcell.KeyPgUp:   "PageUp",
+	tcell.KeyPgDn:   "PageDown",
+}
+
+func init() {
+	for i := 1; i <= 12; i++ {
+		name := fmt.Sprintf("F%d", i)
+		code := tcell.KeyF1 + tcell.Key(i-1)
+		namedKeys[strings.ToLower(name)] = Key{Code: code}
+		keyNames[code] = name
+	}
+}
+
+// FromEvent converts a terminal key event into a Key.
+func FromEvent(ev *tcell.EventKey) Key {
+	if ev.Key() == tcell.KeyRune {
+		return Key{Code: tcell.KeyRune, Rune: ev.Rune(), Mod: ev.Modifiers() & tcell.ModAlt}
+	}
+	if ev.Key() <= tcell.KeyDEL {
+		return Key{Code: ev.Key()}
+	}
+	return Key{Code: ev.Key(), Mod: ev.Modifiers()}
+}
+
+// Event converts the Key back into a terminal key event.
+func (key Key) Event() *tcell.EventKey {
+	return tcell.NewEventKey(key.Code, key.Rune, key.Mod)
+}
+
+// Character returns the rune which the key inserts, if any.
+func (key Key) Character() (r rune, ok bool) {
+	if key.Code == tcell.KeyRune && key.Mod == tcell.ModNone {
+		return key.Rune, true
+	}
+	if key.Code == tcell.KeyTab {
+		return '\t', true
+	}
+	return 0, false
+}
+
+// Parse converts vim key notation, such as "<leader>w" or "jk<Esc>",
+// into a sequence of keys. As in vim, a "<" which does not start a
+// known key name stands for itself. Control characters stand for their
+// keys, and a newline is <CR>.
+func Parse(notation string, leader []Key) []Key {
+	keys := make([]Key, 0)
+	runes := []rune(notation)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '<' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '>' && runes[end] != '<' {
+				end++
+			}
+			if end < len(runes) && runes[end] == '>' && end > i+1 {
+				name := string(runes[i+1 : end])
+				if strings.EqualFold(name, "leader") {
+					keys = append(keys, leader...)
+					i = end
+					continue
+				}
+				if strings.EqualFold(name, "nop") {
+					i = end
+					continue
+				}
+				if key, ok := parseName(name); ok {
+					keys = append(keys, key)
+					i = end
+					continue
+				}
+			}
+		}
+		keys = append(keys, runeKey(runes[i]))
+	}
+	return keys
+}
+
+func runeKey(r rune) Key {
+	switch {
+	case r == '\n':
+		return Key{Code: tcell.KeyEnter}
+	case r < ' ' || r == rune(tcell.KeyDEL):
+		return Key{Code: tcell.Key(r)}
+	}
+	return Key{Code: tcell.KeyRune, Rune: r}
+}
+
+func parseName(name string) (key Key, ok bool) {
+	if key, ok := namedKeys[strings.ToLower(name)]; ok {
+		return key, true
+	}
+	if len(name) < 3 || name[1] != '-' {
+		return Key{}, false
+	}
+	base, ok := parseBase(name[2:])
+	if !ok {
+		return Key{}, false
+	}
+	switch unicode.ToLower(rune(name[0])) {
+	case 'c':
+		return control(base)
+	case 'm', 'a':
+		base.Mod |= tcell.ModAlt
+		return base, true
+	case 's':
+		if base.Code == tcell.KeyTab {
+			return Key{Code: tcell.KeyBacktab}, true
+		}
+		if base.Code == tcell.KeyRune {
+			return Key{Code: tcell.KeyRune, Rune: unicode.ToUpper(base.Rune)}, true
+		}
+		base.Mod |= tcell.ModShift
+		return base, true
+	}
+	return Key{}, false
+}
+
+func parseBase(name string) (key Key, ok bool) {
+	if len([]rune(name)) == 1 {
+		return Key{Code: tcell.KeyRune, Rune: []rune(name)[0]}, true
+	}
+	if key, ok := namedKeys[strings.ToLower(name)]; ok {
+		return key, true
+	}
+	return Key{}, false
+}
+
+func control(base Key) (key Key, ok bool) {
+	if base.Code != tcell.KeyRune {
+		base.Mod |= tcell.ModCtrl
+		return base, true
+	}
+	r := unicode.ToLower(base.Rune)
+	switch {
+	case r >= 'a' && r <= 'z':
+		return Key{Code: tcell.KeyCtrlA + tcell.Key(r-'a')}, true
+	case r == ' ' || r == '@':
+		return Key{Code: tcell.KeyCtrlSpace}, true
+	case r == '[':
+		return Key{Code: tcell.KeyEsc}, true
+	case r == '\\':
+		return Key{Code: tcell.KeyCtrlBackslash}, true
+	case r == ']':
+		return Key{Code: tcell.KeyCtrlRightSq}, true
+	case r == '^':
+		return Key{Code: tcell.KeyCtrlCarat}, true
+	case r == '_':
+		return Key{Code: tcell.KeyCtrlUnderscore}, true
+	}
+	return Key{}, false
+}
+
+// Format converts a sequence of keys into vim key notation, such that
+// parsing the result gives back the same keys.
+func Format(keys []Key) string {
+	var builder strings.Builder
+	for _, key := range keys {
+		builder.WriteString(key.String())
+	}
+	return builder.String()
+}
+
+func (key Key) String() string {
+	prefix := ""
+	if key.Mod&tcell.ModCtrl != 0 {
+		prefix += "C-"
+	}
+	if key.Mod&tcell.ModAlt != 0 {
+		prefix += "M-"
+	}
+	if key.Mod&tcell.ModShift != 0 {
+		prefix += "S-"
+	}
+	if key.Code == tcell.KeyRune {
+		if prefix != "" {
+			return "<" + prefix + string(key.Rune) + ">"
+		}
+		if key.Rune == '<' {
+			return "<lt>"
+		}
+		return string(key.Rune)
+	}
+	if name, ok := keyNames[key.Code]; ok {
+		return "<" + prefix + name + ">"
+	}
+	switch {
+	case key.Code == tcell.KeyBacktab:
+		return "<S-Tab>"
+	case key.Code == tcell.KeyCtrlSpace:
+		return "<C-@>"
+	case key.Code >= tcell.KeyCtrlA && key.Code <= tcell.KeyCtrlZ:
+		return "<C-" + string(rune('a'+key.Code-tcell.KeyCtrlA)) + ">"
+	case key.Code == tcell.KeyCtrlBackslash:
+		return "<C-\\>"
+	case key.Code == tcell.KeyCtrlRightSq:
+		return "<C-]>"
+	case key.Code == tcell.KeyCtrlCarat:
+		return "<C-^>"
+	case key.Code == tcell.KeyCtrlUnderscore:
+		return "<C-_>"
+	}
+	return fmt.Sprintf("<%s%d>", prefix, key.Code)
+}
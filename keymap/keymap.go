@@ -0,0 +1,83 @@
+package keymap
+
+import "sort"
+
+// Mapping replaces the keys typed in From with the keys in To. If the
+// mapping is recursive, the keys in To may themselves be remapped.
+type Mapping struct {
+	From      []Key
+	To        []Key
+	Recursive bool
+}
+
+// Map holds the user-defined mappings of a single mode.
+type Map struct {
+	mappings []*Mapping
+}
+
+func (keyMap *Map) Add(from, to []Key, recursive bool) {
+	mapping := &Mapping{From: from, To: to, Recursive: recursive}
+	for i, existing := range keyMap.mappings {
+		if Equal(existing.From, from) {
+			keyMap.mappings[i] = mapping
+			return
+		}
+	}
+	keyMap.mappings = append(keyMap.mappings, mapping)
+}
+
+func (keyMap *Map) Remove(from []Key) (removed bool) {
+	for i, existing := range keyMap.mappings {
+		if Equal(existing.From, from) {
+			keyMap.mappings = append(keyMap.mappings[:i], keyMap.mappings[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (keyMap *Map) Clear() {
+	keyMap.mappings = nil
+}
+
+// Mappings returns every mapping, ordered by the keys they map from.
+func (keyMap *Map) Mappings() []*Mapping {
+	mappings := make([]*Mapping, len(keyMap.mappings))
+	copy(mappings, keyMap.mappings)
+	sort.Slice(mappings, func(i, j int) bool {
+		return Format(mappings[i].From) < Format(mappings[j].From)
+	})
+	return mappings
+}
+
+// Lookup finds the longest mapping which the typed keys start with. It
+// also reports whether the typed keys are the start of a longer mapping,
+// in which case more keys are needed to know which mapping applies.
+func (keyMap *Map) Lookup(typed []Key) (match *Mapping, ambiguous bool) {
+	for _, mapping := range keyMap.mappings {
+		if len(mapping.From) > len(typed) && HasPrefix(mapping.From, typed) {
+			ambiguous = true
+			continue
+		}
+		if HasPrefix(typed, mapping.From) && (match == nil || len(mapping.From) > len(match.From)) {
+			match = mapping
+		}
+	}
+	return match, ambiguous
+}
+
+func Equal(a, b []Key) bool {
+	return len(a) == len(b) && HasPrefix(a, b)
+}
+
+func HasPrefix(keys, prefix []Key) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+	for i := range prefix {
+		if keys[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
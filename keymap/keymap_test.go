@@ -0,0 +1,136 @@
+package keymap
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func runeKeys(s string) []Key {
+	keys := make([]Key, 0)
+	for _, r := range s {
+		keys = append(keys, Key{Code: tcell.KeyRune, Rune: r})
+	}
+	return keys
+}
+
+func TestParsePlain(t *testing.T) {
+	keys := Parse("jk", nil)
+	if !Equal(keys, runeKeys("jk")) {
+		t.Errorf("bad parse: %v", keys)
+	}
+}
+
+func TestParseSpecial(t *testing.T) {
+	keys := Parse("<Esc><C-d><cr><lt><Space><S-Tab><M-x><C-Up><F5>", nil)
+	expected := []Key{
+		{Code: tcell.KeyEsc},
+		{Code: tcell.KeyCtrlD},
+		{Code: tcell.KeyEnter},
+		{Code: tcell.KeyRune, Rune: '<'},
+		{Code: tcell.KeyRune, Rune: ' '},
+		{Code: tcell.KeyBacktab},
+		{Code: tcell.KeyRune, Rune: 'x', Mod: tcell.ModAlt},
+		{Code: tcell.KeyUp, Mod: tcell.ModCtrl},
+		{Code: tcell.KeyF5},
+	}
+	if !Equal(keys, expected) {
+		t.Errorf("bad parse: %v", keys)
+	}
+}
+
+func TestParseLeader(t *testing.T) {
+	keys := Parse("<leader>w<Nop>", runeKeys(","))
+	if !Equal(keys, runeKeys(",w")) {
+		t.Errorf("bad parse: %v", keys)
+	}
+}
+
+func TestParseLiteralAngle(t *testing.T) {
+	keys := Parse("a<b <foo> <", nil)
+	if !Equal(keys, runeKeys("a<b <foo> <")) {
+		t.Errorf("bad parse: %v", keys)
+	}
+}
+
+func TestParseControlCharacters(t *testing.T) {
+	keys := Parse("a\n\x1b\t", nil)
+	expected := []Key{
+		{Code: tcell.KeyRune, Rune: 'a'},
+		{Code: tcell.KeyEnter},
+		{Code: tcell.KeyEsc},
+		{Code: tcell.KeyTab},
+	}
+	if !Equal(keys, expected) {
+		t.Errorf("bad parse: %v", keys)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	notations := []string{"jk", "<Esc>", "<C-d>x", "<lt>a>", "<S-Tab><M-x>", "<C-Up><F12>", "<CR><BS><Tab>"}
+	for _, notation := range notations {
+		formatted := Format(Parse(notation, nil))
+		if formatted != notation {
+			t.Errorf("bad round trip: %s became %s", notation, formatted)
+		}
+	}
+}
+
+func TestFromEvent(t *testing.T) {
+	key := FromEvent(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModShift))
+	if key != (Key{Code: tcell.KeyRune, Rune: 'a'}) {
+		t.Errorf("bad key: %v", key)
+	}
+	key = FromEvent(tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl))
+	if key != (Key{Code: tcell.KeyCtrlF}) {
+		t.Errorf("bad key: %v", key)
+	}
+	if FromEvent(key.Event()) != key {
+		t.Error("bad event round trip")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	keyMap := Map{}
+	keyMap.Add(runeKeys("j"), runeKeys("gj"), true)
+	keyMap.Add(runeKeys("jk"), Parse("<Esc>", nil), false)
+	match, ambiguous := keyMap.Lookup(runeKeys("j"))
+	if match == nil || !ambiguous {
+		t.Error("expected an ambiguous match")
+	}
+	match, ambiguous = keyMap.Lookup(runeKeys("jk"))
+	if match == nil || ambiguous || match.Recursive {
+		t.Error("expected the longer match")
+	}
+	match, ambiguous = keyMap.Lookup(runeKeys("jx"))
+	if match == nil || ambiguous || !Equal(match.From, runeKeys("j")) {
+		t.Error("expected the shorter match")
+	}
+	match, ambiguous = keyMap.Lookup(runeKeys("x"))
+	if match != nil || ambiguous {
+		t.Error("expected no match")
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	keyMap := Map{}
+	keyMap.Add(runeKeys("b"), runeKeys("x"), true)
+	keyMap.Add(runeKeys("a"), runeKeys("x"), true)
+	keyMap.Add(runeKeys("a"), runeKeys("y"), false)
+	mappings := keyMap.Mappings()
+	if len(mappings) != 2 {
+		t.Fatal("bad mapping count")
+	}
+	if !Equal(mappings[0].From, runeKeys("a")) || !Equal(mappings[0].To, runeKeys("y")) {
+		t.Error("mapping should have been replaced")
+	}
+	if !keyMap.Remove(runeKeys("a")) {
+		t.Error("mapping should have been removed")
+	}
+	if keyMap.Remove(runeKeys("a")) {
+		t.Error("mapping should not exist")
+	}
+	if len(keyMap.Mappings()) != 1 {
+		t.Error("bad mapping count")
+	}
+}
@@ -0,0 +1,384 @@
+package option
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Type int
+
+const (
+	Bool Type = iota
+	Number
+	String
+)
+
+type Scope int
+
+const (
+	Global Scope = iota
+	Local
+)
+
+type definition struct {
+	name     string
+	short    string
+	kind     Type
+	scope    Scope
+	minimum  int
+	list     bool
+	defaults value
+	index    int
+}
+
+type value struct {
+	boolean bool
+	number  int
+	text    string
+}
+
+var definitions = []definition{
+	{name: "autoindent", short: "ai", kind: Bool, scope: Local, defaults: value{boolean: true}},
+	{name: "bomb", kind: Bool, scope: Local},
+	{name: "cmdwinheight", short: "cwh", kind: Number, scope: Global, minimum: 1, defaults: value{number: 7}},
+	{name: "endofline", short: "eol", kind: Bool, scope: Local, defaults: value{boolean: true}},
+	{name: "errorformat", short: "efm", kind: String, scope: Global, list: true, defaults: value{text: "%f:%l:%c: %m,%f:%l: %m"}},
+	{name: "expandtab", short: "et", kind: Bool, scope: Local},
+	{name: "fileencoding", short: "fenc", kind: String, scope: Local, defaults: value{text: "utf-8"}},
+	{name: "fileformat", short: "ff", kind: String, scope: Local, defaults: value{text: "unix"}},
+	{name: "filetype", short: "ft", kind: String, scope: Local},
+	{name: "fixendofline", short: "fixeol", kind: Bool, scope: Local, defaults: value{boolean: true}},
+	{name: "history", short: "hi", kind: Number, scope: Global, defaults: value{number: 50}},
+	{name: "hlsearch", short: "hls", kind: Bool, scope: Global},
+	{name: "ignorecase", short: "ic", kind: Bool, scope: Global},
+	{name: "incsearch", short: "is", kind: Bool, scope: Global, defaults: value{boolean: true}},
+	{name: "iskeyword", short: "isk", kind: String, scope: Local, list: true, defaults: value{text: "@,48-57,_,192-255"}},
+	{name: "makeprg", short: "mp", kind: String, scope: Global, defaults: value{text: "go build ./..."}},
+	{name: "mapleader", kind: String, scope: Global, defaults: value{text: "\\"}},
+	{name: "number", short: "nu", kind: Bool, scope: Global},
+	{name: "scroll", short: "scr", kind: Number, scope: Global},
+	{name: "scrolloff", short: "so", kind: Number, scope: Global},
+	{name: "shiftwidth", short: "sw", kind: Number, scope: Local, defaults: value{number: 8}},
+	{name: "smartcase", short: "scs", kind: Bool, scope: Global},
+	{name: "smartindent", short: "si", kind: Bool, scope: Local, defaults: value{boolean: true}},
+	{name: "softtabstop", short: "sts", kind: Number, scope: Local, minimum: -1},
+	{name: "tabstop", short: "ts", kind: Number, scope: Local, minimum: 1, defaults: value{number: 8}},
+	{name: "timeoutlen", short: "tm", kind: Number, scope: Global, defaults: value{number: 1000}},
+}
+
+// byName finds a definition by its name or short name, since options are
+// looked up for every character which is drawn or measured. The index of a
+// definition is where its value is kept.
+var byName map[string]*definition
+
+func init() {
+	byName = make(map[string]*definition, 2*len(definitions))
+	for i := range definitions {
+		definitions[i].index = i
+		byName[definitions[i].name] = &definitions[i]
+		if definitions[i].short != "" {
+			byName[definitions[i].short] = &definitions[i]
+		}
+	}
+}
+
+// Options holds the value of every option. A global set has no parent,
+// while a buffer-local set refers to the global set for global options.
+type Options struct {
+	global *Options
+	values []value
+}
+
+func (options *Options) Init(global *Options) {
+	options.global = global
+	options.values = make([]value, len(definitions))
+	for i, def := range definitions {
+		if global != nil && def.scope == Global {
+			continue
+		}
+		options.values[i] = def.defaults
+		if global != nil {
+			options.values[i] = global.values[i]
+		}
+	}
+}
+
+// Names returns the full name of every option, sorted alphabetically.
+func Names() []string {
+	names := make([]string, 0, len(definitions))
+	for _, def := range definitions {
+		names = append(names, def.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsBool reports whether the option is turned on and off, rather than
+// having a value.
+func IsBool(name string) bool {
+	def := lookup(name)
+	return def != nil && def.kind == Bool
+}
+
+func (options *Options) Bool(name string) bool {
+	return options.get(name).boolean
+}
+
+func (options *Options) Number(name string) int {
+	return options.get(name).number
+}
+
+func (options *Options) String(name string) string {
+	return options.get(name).text
+}
+
+func (options *Options) get(name string) value {
+	def := lookup(name)
+	if def == nil {
+		panic("unknown option " + name)
+	}
+	return options.owner(def).values[def.index]
+}
+
+func (options *Options) owner(def *definition) *Options {
+	if def.scope == Global && options.global != nil {
+		return options.global
+	}
+	return options
+}
+
+func (options *Options) put(def *definition, val value) {
+	options.owner(def).values[def.index] = val
+	if def.scope == Local && options.global != nil {
+		options.global.values[def.index] = val
+	}
+}
+
+func lookup(name string) *definition {
+	return byName[name]
+}
+
+// Set applies the arguments of a set command, such as "ts=4 noet sw?",
+// and returns the text of any options which were queried.
+func (options *Options) Set(arguments string) (message string, err error) {
+	shown := make([]string, 0)
+	for _, argument := range splitArguments(arguments) {
+		text, err := options.setOne(argument)
+		if err != nil {
+			return strings.Join(shown, " "), err
+		}
+		if text != "" {
+			shown = append(shown, text)
+		}
+	}
+	return strings.Join(shown, " "), nil
+}
+
+// SetLocal is Set which leaves the global value of buffer-local options
+// alone, for settings which come from the file rather than the user.
+func (options *Options) SetLocal(arguments string) (message string, err error) {
+	global := options.global
+	options.global = nil
+	defer func() {
+		options.global = global
+	}()
+	return options.Set(arguments)
+}
+
+func (options *Options) setOne(argument string) (message string, err error) {
+	name, operator, text := splitAssignment(argument)
+	if operator == "" && strings.HasSuffix(name, "?") {
+		def := lookup(strings.TrimSuffix(name, "?"))
+		if def == nil {
+			return "", fmt.Errorf("unknown option: %s", argument)
+		}
+		return options.show(def), nil
+	}
+	if operator == "" && strings.HasSuffix(name, "&") {
+		def := lookup(strings.TrimSuffix(name, "&"))
+		if def == nil {
+			return "", fmt.Errorf("unknown option: %s", argument)
+		}
+		options.put(def, def.defaults)
+		return "", nil
+	}
+	if operator == "" && strings.HasSuffix(name, "!") {
+		def := lookup(strings.TrimSuffix(name, "!"))
+		if def == nil || def.kind != Bool {
+			return "", fmt.Errorf("invalid argument: %s", argument)
+		}
+		options.toggle(def)
+		return "", nil
+	}
+	def := lookup(name)
+	if operator == "" && def == nil {
+		if strings.HasPrefix(name, "no") {
+			if negated := lookup(name[2:]); negated != nil && negated.kind == Bool {
+				options.put(negated, value{boolean: false})
+				return "", nil
+			}
+		}
+		if strings.HasPrefix(name, "inv") {
+			if inverted := lookup(name[3:]); inverted != nil && inverted.kind == Bool {
+				options.toggle(inverted)
+				return "", nil
+			}
+		}
+	}
+	if def == nil {
+		return "", fmt.Errorf("unknown option: %s", name)
+	}
+	if operator == "" {
+		if def.kind == Bool {
+			options.put(def, value{boolean: true})
+			return "", nil
+		}
+		return options.show(def), nil
+	}
+	if def.kind == Bool {
+		return "", fmt.Errorf("invalid argument: %s", argument)
+	}
+	return "", options.assign(def, operator, text)
+}
+
+func (options *Options) toggle(def *definition) {
+	val := options.owner(def).values[def.index]
+	options.put(def, value{boolean: !val.boolean})
+}
+
+func (options *Options) assign(def *definition, operator, text string) error {
+	current := options.owner(def).values[def.index]
+	if def.list {
+		options.put(def, value{text: assignList(current.text, operator, text)})
+		return nil
+	}
+	if def.kind == String {
+		switch operator {
+		case "+=":
+			text = current.text + text
+		case "^=":
+			text = text + current.text
+		case "-=":
+			text = strings.Replace(current.text, text, "", 1)
+		}
+		options.put(def, value{text: text})
+		return nil
+	}
+	number, err := strconv.Atoi(text)
+	if err != nil {
+		return fmt.Errorf("number required after =: %s=%s", def.name, text)
+	}
+	switch operator {
+	case "+=":
+		number = current.number + number
+	case "-=":
+		number = current.number - number
+	case "^=":
+		number = current.number * number
+	}
+	if number < def.minimum {
+		return fmt.Errorf("argument must be at least %d: %s", def.minimum, def.name)
+	}
+	options.put(def, value{number: number})
+	return nil
+}
+
+func (options *Options) show(def *definition) string {
+	val := options.owner(def).values[def.index]
+	switch def.kind {
+	case Bool:
+		if val.boolean {
+			return def.name
+		}
+		return "no" + def.name
+	case Number:
+		return def.name + "=" + strconv.Itoa(val.number)
+	default:
+		return def.name + "=" + val.text
+	}
+}
+
+// assignList is assign for a comma separated list, where "+=", "^=" and
+// "-=" add or remove a whole item.
+func assignList(current, operator, text string) string {
+	items := strings.Split(current, ",")
+	if current == "" {
+		items = nil
+	}
+	index := -1
+	for i, item := range items {
+		if item == text {
+			index = i
+		}
+	}
+	switch operator {
+	case "+=":
+		if index < 0 && text != "" {
+			items = append(items, text)
+		}
+	case "^=":
+		if index < 0 && text != "" {
+			items = append([]string{text}, items...)
+		}
+	case "-=":
+		if index >= 0 {
+			items = append(items[:index], items[index+1:]...)
+		}
+	default:
+		return text
+	}
+	return strings.Join(items, ",")
+}
+
+func splitAssignment(argument string) (name, operator, text string) {
+	index := strings.IndexAny(argument, "=:")
+	if index <= 0 {
+		return argument, "", ""
+	}
+	name = argument[:index]
+	operator = "="
+	if strings.ContainsAny(name[len(name)-1:], "+-^") {
+		operator = name[len(name)-1:] + "="
+		name = name[:len(name)-1]
+	}
+	return name, operator, argument[index+1:]
+}
+
+// splitArguments splits on whitespace, except where the whitespace is
+// escaped with a backslash.
+func splitArguments(arguments string) []string {
+	split := make([]string, 0)
+	current := make([]rune, 0)
+	escaped := false
+	for _, r := range arguments {
+		if escaped {
+			if r != ' ' && r != '\\' && r != '"' && r != '|' {
+				current = append(current, '\\')
+			}
+			current = append(current, r)
+			escaped = false
+			continue
+		}
+		if r == '\\' {
+			escaped = true
+			continue
+		}
+		if r == ' ' || r == '\t' {
+			if len(current) > 0 {
+				split = append(split, string(current))
+				current = current[:0]
+			}
+			continue
+		}
+		current = append(current, r)
+	}
+	if escaped {
+		current = append(current, '\\')
+	}
+	if len(current) > 0 {
+		split = append(split, string(current))
+	}
+	return split
+}
@@ -0,0 +1,185 @@
+package option
+
+import "testing"
+
+func newOptions() (global *Options, local *Options) {
+	global = &Options{}
+	global.Init(nil)
+	local = &Options{}
+	local.Init(global)
+	return global, local
+}
+
+func TestDefaults(t *testing.T) {
+	global, local := newOptions()
+	if global.Number("tabstop") != 8 || local.Number("ts") != 8 {
+		t.Error("bad default tabstop")
+	}
+	if global.Bool("number") || local.Bool("expandtab") {
+		t.Error("bad default boolean")
+	}
+}
+
+func TestSetBool(t *testing.T) {
+	_, local := newOptions()
+	if _, err := local.Set("number"); err != nil {
+		t.Error(err)
+	}
+	if !local.Bool("number") {
+		t.Error("option should be on")
+	}
+	if _, err := local.Set("nonu"); err != nil {
+		t.Error(err)
+	}
+	if local.Bool("number") {
+		t.Error("option should be off")
+	}
+	if _, err := local.Set("invnumber"); err != nil {
+		t.Error(err)
+	}
+	if !local.Bool("number") {
+		t.Error("option should be toggled on")
+	}
+	if _, err := local.Set("nu!"); err != nil {
+		t.Error(err)
+	}
+	if local.Bool("number") {
+		t.Error("option should be toggled off")
+	}
+}
+
+func TestSetNumber(t *testing.T) {
+	_, local := newOptions()
+	if _, err := local.Set("ts=4"); err != nil {
+		t.Error(err)
+	}
+	if local.Number("tabstop") != 4 {
+		t.Error("bad assignment")
+	}
+	if _, err := local.Set("ts+=2"); err != nil {
+		t.Error(err)
+	}
+	if local.Number("tabstop") != 6 {
+		t.Error("bad addition")
+	}
+	if _, err := local.Set("ts-=3"); err != nil {
+		t.Error(err)
+	}
+	if local.Number("tabstop") != 3 {
+		t.Error("bad subtraction")
+	}
+	if _, err := local.Set("ts&"); err != nil {
+		t.Error(err)
+	}
+	if local.Number("tabstop") != 8 {
+		t.Error("bad reset")
+	}
+}
+
+func TestSetList(t *testing.T) {
+	_, local := newOptions()
+	if _, err := local.Set("isk=a-z,_"); err != nil {
+		t.Error(err)
+	}
+	local.Set("isk+=-")
+	local.Set("isk+=_")
+	if local.String("iskeyword") != "a-z,_,-" {
+		t.Errorf("bad addition: %s", local.String("iskeyword"))
+	}
+	local.Set("isk^=@")
+	local.Set("isk-=_")
+	if local.String("iskeyword") != "@,a-z,-" {
+		t.Errorf("bad removal: %s", local.String("iskeyword"))
+	}
+}
+
+func TestSetMultiple(t *testing.T) {
+	_, local := newOptions()
+	message, err := local.Set("ts=2 sw:2  et sw? nu?")
+	if err != nil {
+		t.Error(err)
+	}
+	if message != "shiftwidth=2 nonumber" {
+		t.Errorf("bad message: %s", message)
+	}
+	if local.Number("tabstop") != 2 || !local.Bool("expandtab") {
+		t.Error("bad assignment")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	_, local := newOptions()
+	message, err := local.Set("tabstop")
+	if err != nil {
+		t.Error(err)
+	}
+	if message != "tabstop=8" {
+		t.Errorf("bad message: %s", message)
+	}
+	message, _ = local.Set("expandtab?")
+	if message != "noexpandtab" {
+		t.Errorf("bad message: %s", message)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, local := newOptions()
+	bad := []string{"foo", "nofoo", "ts=abc", "ts=0", "nu=3", "ts!", "foo?", "nots"}
+	for _, argument := range bad {
+		if _, err := local.Set(argument); err == nil {
+			t.Errorf("expected an error for %s", argument)
+		}
+	}
+	if local.Number("tabstop") != 8 {
+		t.Error("failed assignment should not change the value")
+	}
+}
+
+func TestScopes(t *testing.T) {
+	global, local := newOptions()
+	other := &Options{}
+	other.Init(global)
+	if _, err := local.Set("nu ts=4"); err != nil {
+		t.Error(err)
+	}
+	if !other.Bool("number") {
+		t.Error("global option should be shared")
+	}
+	if other.Number("tabstop") != 8 {
+		t.Error("local option should not leak into existing buffers")
+	}
+	if global.Number("tabstop") != 4 {
+		t.Error("local option should become the new global value")
+	}
+	newer := &Options{}
+	newer.Init(global)
+	if newer.Number("tabstop") != 4 {
+		t.Error("new buffer should copy the global value")
+	}
+}
+
+func TestSetLocal(t *testing.T) {
+	global, local := newOptions()
+	if _, err := local.SetLocal("ts=4 ff=dos"); err != nil {
+		t.Error(err)
+	}
+	if local.Number("tabstop") != 4 || local.String("fileformat") != "dos" {
+		t.Error("local option should be set")
+	}
+	if global.Number("tabstop") != 8 || global.String("fileformat") != "unix" {
+		t.Error("local option should not change the global value")
+	}
+}
+
+func TestSplitArguments(t *testing.T) {
+	split := splitArguments(`a=b\ c  d \\x e\|f`)
+	expected := []string{"a=b c", "d", `\x`, "e|f"}
+	if len(split) != len(expected) {
+		t.Fatalf("bad split: %q", split)
+	}
+	for i := range split {
+		if split[i] != expected[i] {
+			t.Errorf("bad split: %q", split)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package screen
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bkthomps/Ven/buffer"
+)
+
+var (
+	invalidRange = errors.New("Invalid Range")
+	noRange      = errors.New("No Range Allowed")
+)
+
+// lineRange is the lines which an ex command acts on, given before the
+// name of the command, such as "'a,'b", "5" or "%". The first and last
+// lines are the current line when no address is given.
+type lineRange struct {
+	first     int
+	last      int
+	addresses int
+}
+
+// parseRange parses the range at the start of an ex command line, and
+// returns the rest of the line.
+func (screen *Screen) parseRange(line string) (lines lineRange, rest string, err error) {
+	file := screen.file.buffer
+	current := file.LineNumber(file.Current)
+	lines = lineRange{first: current, last: current}
+	if strings.HasPrefix(line, "%") {
+		return lineRange{first: 1, last: file.Lines, addresses: 2}, line[1:], nil
+	}
+	for {
+		number, remaining, found, err := screen.parseAddress(line)
+		if err != nil {
+			return lines, line, err
+		}
+		if found {
+			lines.first, lines.last = lines.last, number
+			lines.addresses++
+			line = remaining
+		}
+		if !strings.HasPrefix(line, ",") && !strings.HasPrefix(line, ";") {
+			break
+		}
+		if !found {
+			lines.first, lines.last = lines.last, current
+			lines.addresses++
+		}
+		line = line[1:]
+	}
+	if lines.addresses == 1 {
+		lines.first = lines.last
+	}
+	if lines.first > lines.last {
+		lines.first, lines.last = lines.last, lines.first
+	}
+	if lines.first < 0 || lines.last > file.Lines {
+		// A range with no command after it only moves the cursor, so it
+		// stops at the first or last line rather than failing.
+		if strings.TrimSpace(line) != "" {
+			return lines, line, invalidRange
+		}
+		if lines.first < 1 {
+			lines.first = 1
+		}
+		if lines.last < 1 {
+			lines.last = 1
+		} else if lines.last > file.Lines {
+			lines.last = file.Lines
+		}
+	}
+	return lines, line, nil
+}
+
+// parseAddress parses a line number, ".", "$" or a mark such as "'a",
+// followed by any number of offsets such as "+2" or "-".
+func (screen *Screen) parseAddress(text string) (number int, rest string, found bool, err error) {
+	file := screen.file.buffer
+	number = file.LineNumber(file.Current)
+	switch {
+	case text == "":
+		return number, text, false, nil
+	case unicode.IsDigit(rune(text[0])):
+		end := 0
+		for end < len(text) && unicode.IsDigit(rune(text[end])) {
+			end++
+		}
+		number, _ = strconv.Atoi(text[:end])
+		text, found = text[end:], true
+	case text[0] == '.':
+		text, found = text[1:], true
+	case text[0] == '$':
+		number = file.Lines
+		text, found = text[1:], true
+	case text[0] == '\'':
+		mark := []rune(text[1:])
+		if len(mark) == 0 {
+			return number, text, false, invalidRange
+		}
+		position, err := screen.markPosition(mark[0], false)
+		if err != nil {
+			return number, text, false, err
+		}
+		number = file.LineNumber(position.Line)
+		text, found = text[1+len(string(mark[0])):], true
+	}
+	for len(text) > 0 && (text[0] == '+' || text[0] == '-') {
+		sign := 1
+		if text[0] == '-' {
+			sign = -1
+		}
+		end := 1
+		for end < len(text) && unicode.IsDigit(rune(text[end])) {
+			end++
+		}
+		offset := 1
+		if end > 1 {
+			offset, _ = strconv.Atoi(text[1:end])
+		}
+		number += sign * offset
+		text, found = text[end:], true
+	}
+	return number, text, found, nil
+}
+
+// gotoLine is an ex command line which is only a range, such as ":12",
+// which goes to the first non-blank rune of the last line of the range.
+func (screen *Screen) gotoLine(lines lineRange) {
+	file := screen.file.buffer
+	from := screen.here()
+	line := file.LineAt(lines.last)
+	file.SetCursor(buffer.Position{Line: line, Offset: line.FirstNonBlank()}, false)
+	if line != from.position.Line {
+		screen.recordJump(from)
+	}
+	screen.followCursor()
+}
@@ -0,0 +1,175 @@
+package screen
+
+import (
+	"errors"
+
+	"github.com/bkthomps/Ven/buffer"
+	"github.com/bkthomps/Ven/option"
+)
+
+var (
+	invalidInCommandWindow = errors.New("Invalid In Command-Line Window")
+	commandWindowTitle     = []rune("[Command Line]")
+)
+
+// commandWindow is the command-line window of "q:" and "q/", which holds
+// the history of commands or searches in a buffer below the file, where
+// it can be edited like any other. The windows of :copen and :lopen are
+// ones too, with a line for each entry of the quickfix list or the
+// location list. While it is open, the screen's file is the window, and
+// the file which was on the screen is kept in other.
+type commandWindow struct {
+	kind       rune
+	other      *file
+	otherFirst *buffer.Line
+	swapped    bool
+}
+
+// openCommandWindow puts each entry of the history on its own line, with
+// the typed text on the last line, where the cursor starts at its end.
+func (screen *Screen) openCommandWindow(kind rune, typed []rune) {
+	if screen.cmdwin != nil {
+		screen.message = errorText(invalidInCommandWindow)
+		screen.fail()
+		return
+	}
+	kind = historyKind(kind)
+	lines := append(append([]string{}, screen.histories[kind]...), string(typed))
+	screen.openWindow(kind, lines, len(lines), len(typed))
+}
+
+// openWindow puts the lines in a buffer in a window below the file, with
+// the cursor at the offset into the given line.
+func (screen *Screen) openWindow(kind rune, lines []string, lineNumber, offset int) {
+	buf := &buffer.File{Options: &option.Options{}}
+	buf.Options.Init(screen.options)
+	buf.Init("")
+	for i, line := range lines {
+		if i > 0 {
+			buf.Add('\n')
+		}
+		for _, r := range line {
+			buf.Add(r)
+		}
+	}
+	buf.SetCursor(buffer.Position{Line: buf.LineAt(lineNumber), Offset: offset}, false)
+	screen.cmdwin = &commandWindow{kind: kind, other: screen.file, otherFirst: screen.firstLine}
+	screen.file = &file{buffer: buf}
+	screen.firstLine = buf.First
+	screen.mode = normalMode
+	screen.updateProperties()
+	screen.followCursor()
+	screen.completeDraw(nil)
+}
+
+// commandToWindow is ctrl-f on the command line, which opens the
+// command-line window with what has been typed.
+func (screen *Screen) commandToWindow() {
+	typed := screen.command.current.Data
+	screen.mode = normalMode
+	screen.incrementalSearch()
+	screen.openCommandWindow(typed[0], typed[1:])
+}
+
+// closeCommandWindow gives the whole screen back to the file, dropping any
+// jumps and marks into the window.
+func (screen *Screen) closeCommandWindow() {
+	closed := screen.file.buffer
+	screen.file = screen.cmdwin.other
+	screen.firstLine = screen.cmdwin.otherFirst
+	screen.cmdwin = nil
+	screen.dropLocations(closed)
+	screen.updateProperties()
+	screen.followCursor()
+	screen.completeDraw(nil)
+}
+
+// runCommandWindowLine is enter in the command-line window, which closes
+// it and runs the line under the cursor as a command or search. In the
+// window of the quickfix list or the location list, it goes to the entry
+// under the cursor.
+func (screen *Screen) runCommandWindowLine() {
+	line := append([]rune{}, screen.file.buffer.Current.Data...)
+	kind := screen.cmdwin.kind
+	if kind == quickfixKind || kind == locationKind {
+		index := screen.file.buffer.LineNumber(screen.file.buffer.Current) - 1
+		screen.closeCommandWindow()
+		screen.mode = normalMode
+		if err := screen.listJump(kind, index); err != nil {
+			screen.message = errorText(err)
+			screen.fail()
+		}
+		return
+	}
+	screen.closeCommandWindow()
+	screen.mode = normalMode
+	if len(line) == 0 {
+		return
+	}
+	screen.startCommand(kind)
+	for _, r := range line {
+		screen.commandInsert(r)
+	}
+	screen.executeCommand()
+}
+
+// layoutCommandWindow splits the lines above the command line between
+// the file and the command-line window, which has cmdwinheight lines and
+// a title line above it, while leaving the file at least one line.
+func (screen *Screen) layoutCommandWindow(height, width int) {
+	size := screen.options.Number("cmdwinheight")
+	if size > height-2 {
+		size = height - 2
+	}
+	if size < 1 {
+		size = 1
+	}
+	other := screen.cmdwin.other
+	other.top = 0
+	other.height = height - size - 1
+	other.width = width
+	screen.file.top = height - size
+	screen.file.height = size
+	screen.file.width = width
+	screen.inOtherWindow(screen.followCursor)
+}
+
+// inOtherWindow runs the function with the file which is not being edited
+// as the screen's file, such as to draw it. Visual mode belongs to the
+// command-line window, so the other window is treated as in normal mode.
+func (screen *Screen) inOtherWindow(run func()) {
+	window := screen.cmdwin
+	mode := screen.mode
+	screen.mode = normalMode
+	screen.file, window.other = window.other, screen.file
+	screen.firstLine, window.otherFirst = window.otherFirst, screen.firstLine
+	window.swapped = true
+	run()
+	window.swapped = false
+	screen.file, window.other = window.other, screen.file
+	screen.firstLine, window.otherFirst = window.otherFirst, screen.firstLine
+	screen.mode = mode
+}
+
+// drawOtherWindow draws the file above the command-line window, and the
+// title line between them.
+func (screen *Screen) drawOtherWindow() {
+	screen.inOtherWindow(func() {
+		screen.drawWindow(nil)
+	})
+	title := commandWindowTitle
+	switch screen.cmdwin.kind {
+	case quickfixKind:
+		title = quickfixTitle
+	case locationKind:
+		title = locationTitle
+	}
+	y := screen.file.top - 1
+	for x := 0; x < screen.width; x++ {
+		r := ' '
+		if x < len(title) {
+			r = title[x]
+		}
+		screen.tCell.SetContent(x, y, r, nil, menuStyle)
+	}
+}
@@ -1,126 +1,475 @@
 package screen
 
 import (
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/bkthomps/Ven/buffer"
-	"github.com/bkthomps/Ven/search"
+	"github.com/bkthomps/Ven/keymap"
 	"github.com/gdamore/tcell/v2"
 )
 
-func (screen *Screen) executeCommandMode(ev *tcell.EventKey, quit chan struct{}) {
-	switch ev.Key() {
-	case tcell.KeyEsc:
-		screen.mode = normalMode
-	case tcell.KeyEnter:
-		screen.executeCommand(quit)
-	case tcell.KeyDown, tcell.KeyUp:
-		// Do Nothing
-	case tcell.KeyLeft:
-		if screen.command.runeOffset > 1 {
-			screen.command.runeOffset--
-			r := screen.command.current.Data[screen.command.runeOffset]
-			runes := screen.command.current.Data
-			runeOffset := screen.command.runeOffset
-			spaceOffset := screen.command.spaceOffset
-			screen.command.spaceOffset = buffer.RuneWidthBackJump(r, runes, runeOffset, spaceOffset)
-		}
-	case tcell.KeyRight:
-		if screen.command.runeOffset < len(screen.command.current.Data) {
-			r := screen.command.current.Data[screen.command.runeOffset]
-			screen.command.spaceOffset = buffer.RuneWidthJump(r, screen.command.spaceOffset)
-			screen.command.runeOffset++
-		}
-	case tcell.KeyDEL:
-		if screen.command.runeOffset == 1 && len(screen.command.current.Data) > 1 {
-			break
+var commandBindings bindings
+
+func init() {
+	commandBindings = newBindings([]binding{
+		{"<Esc>", func(screen *Screen) { screen.mode = normalMode }},
+		{"<CR>", (*Screen).executeCommand},
+		{"<Left>", (*Screen).commandLeft},
+		{"<Right>", (*Screen).commandRight},
+		{"<Up>", func(screen *Screen) { screen.commandRecall(true) }},
+		{"<Down>", func(screen *Screen) { screen.commandRecall(false) }},
+		{"<Home>", func(screen *Screen) { screen.commandMoveTo(1) }},
+		{"<End>", func(screen *Screen) { screen.commandMoveTo(len(screen.command.current.Data)) }},
+		{"<C-a>", func(screen *Screen) { screen.commandMoveTo(1) }},
+		{"<C-e>", func(screen *Screen) { screen.commandMoveTo(len(screen.command.current.Data)) }},
+		{"<BS>", (*Screen).commandBackspace},
+		{"<C-h>", (*Screen).commandBackspace},
+		{"<C-w>", (*Screen).commandDeleteWord},
+		{"<C-u>", func(screen *Screen) { screen.commandDeleteTo(1) }},
+		{"<Tab>", func(screen *Screen) { screen.commandComplete(true) }},
+		{"<S-Tab>", func(screen *Screen) { screen.commandComplete(false) }},
+		{"<C-f>", (*Screen).commandToWindow},
+		{"<C-r>", func(screen *Screen) { screen.command.awaitingRegister = true }},
+	})
+}
+
+func (screen *Screen) executeCommandMode(key keymap.Key) {
+	defer screen.incrementalSearch()
+	if key.Code != tcell.KeyUp && key.Code != tcell.KeyDown {
+		screen.command.recall = nil
+	}
+	if key.Code != tcell.KeyTab && key.Code != tcell.KeyBacktab {
+		screen.endCompletion()
+	}
+	if screen.command.awaitingRegister {
+		screen.command.awaitingRegister = false
+		if r, ok := key.Character(); ok {
+			screen.commandInsertRegister(r)
 		}
+		return
+	}
+	if action, _ := commandBindings.lookup([]keymap.Key{key}); action != nil {
+		action(screen)
+		return
+	}
+	if r, ok := key.Character(); ok {
+		screen.commandInsert(r)
+	}
+}
+
+func (screen *Screen) commandInsert(r rune) {
+	screen.command.current.AddAt(screen.command.runeOffset, r)
+	screen.command.runeOffset++
+	screen.command.spaceOffset = buffer.RuneWidthJump(r, screen.command.spaceOffset, buffer.TabSize)
+}
+
+func (screen *Screen) commandLeft() {
+	if screen.command.runeOffset > 1 {
 		screen.command.runeOffset--
 		r := screen.command.current.Data[screen.command.runeOffset]
 		runes := screen.command.current.Data
 		runeOffset := screen.command.runeOffset
 		spaceOffset := screen.command.spaceOffset
-		screen.command.spaceOffset = buffer.RuneWidthBackJump(r, runes, runeOffset, spaceOffset)
-		screen.command.current.RemoveAt(screen.command.runeOffset)
-		if len(screen.command.current.Data) == 0 {
-			screen.mode = normalMode
-		}
-	default:
-		screen.command.current.AddAt(screen.command.runeOffset, ev.Rune())
+		screen.command.spaceOffset = buffer.RuneWidthBackJump(r, runes, runeOffset, spaceOffset, buffer.TabSize)
+	}
+}
+
+func (screen *Screen) commandRight() {
+	if screen.command.runeOffset < len(screen.command.current.Data) {
+		r := screen.command.current.Data[screen.command.runeOffset]
+		screen.command.spaceOffset = buffer.RuneWidthJump(r, screen.command.spaceOffset, buffer.TabSize)
 		screen.command.runeOffset++
-		screen.command.spaceOffset = buffer.RuneWidthJump(ev.Rune(), screen.command.spaceOffset)
 	}
 }
 
-func (screen *Screen) executeCommand(quit chan struct{}) {
-	if len(screen.command.current.Data) > 1 && screen.command.current.Data[0] == '/' {
-		pattern := screen.command.current.Data[1:]
-		matches, firstLineIndex, err :=
-			search.AllMatches(string(pattern), screen.firstLine, screen.file.height)
-		if err != nil {
-			screen.displayError(badRegex)
+// commandMoveTo puts the cursor of the command line before the rune at
+// the offset, where the offset of the first rune after the ":" is 1.
+func (screen *Screen) commandMoveTo(offset int) {
+	screen.command.runeOffset = offset
+	screen.command.spaceOffset = 0
+	for _, r := range screen.command.current.Data[:offset] {
+		screen.command.spaceOffset = buffer.RuneWidthJump(r, screen.command.spaceOffset, buffer.TabSize)
+	}
+}
+
+// setCommandText replaces what is typed after the ":" with the text, and
+// puts the cursor at the end of it.
+func (screen *Screen) setCommandText(text []rune) {
+	data := append([]rune{screen.command.current.Data[0]}, text...)
+	screen.command.current.Data = data
+	screen.commandMoveTo(len(data))
+}
+
+// commandDeleteTo removes the text from the offset up to the cursor.
+func (screen *Screen) commandDeleteTo(offset int) {
+	data := screen.command.current.Data
+	removed := append(append([]rune{}, data[:offset]...), data[screen.command.runeOffset:]...)
+	screen.command.current.Data = removed
+	screen.commandMoveTo(offset)
+}
+
+// commandDeleteWord is ctrl-w, which removes the whitespace before the
+// cursor and then the word before it.
+func (screen *Screen) commandDeleteWord() {
+	data := screen.command.current.Data
+	offset := screen.command.runeOffset
+	for offset > 1 && unicode.IsSpace(data[offset-1]) {
+		offset--
+	}
+	if offset > 1 {
+		isWord := isCommandWord(data[offset-1])
+		for offset > 1 && !unicode.IsSpace(data[offset-1]) && isCommandWord(data[offset-1]) == isWord {
+			offset--
+		}
+	}
+	screen.commandDeleteTo(offset)
+}
+
+func isCommandWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// commandInsertRegister is ctrl-r, which types the text of a register. The
+// ":" and "/" registers hold the last command and search.
+func (screen *Screen) commandInsertRegister(name rune) {
+	var text []rune
+	if entries, ok := screen.histories[name]; ok {
+		if len(entries) == 0 {
 			return
 		}
-		if len(matches) > 0 && firstLineIndex > screen.file.height-screen.file.yCursor {
-			for i := 0; i < firstLineIndex-1; i++ {
-				_, x := screen.file.buffer.Down(screen.mode == insertMode)
-				screen.file.xCursor = x
-				screen.firstLine = screen.firstLine.Next
-			}
+		text = []rune(entries[len(entries)-1])
+	} else {
+		stored, err := screen.registers.load(name)
+		if err != nil {
+			return
 		}
-		screen.mode = highlightMode
-		screen.completeDraw(matches)
+		text = stored.text
+	}
+	if len(text) > 0 && text[len(text)-1] == '\n' {
+		text = text[:len(text)-1]
+	}
+	for _, r := range text {
+		screen.commandInsert(r)
+	}
+}
+
+func (screen *Screen) commandBackspace() {
+	if screen.command.runeOffset == 1 && len(screen.command.current.Data) > 1 {
 		return
 	}
-	arguments := strings.Fields(string(screen.command.current.Data))
-	action := arguments[0]
-	if action == ":q" {
-		if screen.file.buffer.CanSafeQuit() {
-			close(quit)
-		} else {
-			screen.displayError(modifiedFile)
-		}
+	screen.command.runeOffset--
+	r := screen.command.current.Data[screen.command.runeOffset]
+	runes := screen.command.current.Data
+	runeOffset := screen.command.runeOffset
+	spaceOffset := screen.command.spaceOffset
+	screen.command.spaceOffset = buffer.RuneWidthBackJump(r, runes, runeOffset, spaceOffset, buffer.TabSize)
+	screen.command.current.RemoveAt(screen.command.runeOffset)
+	if len(screen.command.current.Data) == 0 {
+		screen.mode = normalMode
+	}
+}
+
+func (screen *Screen) executeCommand() {
+	screen.addHistory(screen.command.current.Data[0], string(screen.command.current.Data[1:]))
+	if kind := screen.command.current.Data[0]; kind == '/' || kind == '?' {
+		screen.runSearch()
 		return
 	}
-	if action == ":q!" {
-		close(quit)
+	err := screen.runCommand(string(screen.command.current.Data[1:]))
+	if err != nil {
+		screen.displayError(err)
 		return
 	}
-	if action == ":w" || action == ":wq" {
-		fileArguments := len(arguments) - 1
-		if fileArguments == 1 {
-			screen.file.buffer.Name = arguments[1]
-		}
-		if fileArguments > 1 {
-			screen.displayError(tooManyFiles)
-			return
-		}
-		if fileArguments == 0 && screen.file.buffer.Name == "" {
-			screen.displayError(noFilename)
-			return
+	if screen.mode == commandMode {
+		screen.mode = normalMode
+	}
+}
+
+// exCommand is a command which is typed after a colon. Commands which
+// are ranged act on the lines of a range such as "'a,'b", or on the
+// current line when no range is given. Only the commands which are config
+// can be run from the configuration file, since it is run before there is
+// a file.
+type exCommand struct {
+	name     string
+	minimum  int
+	ranged   bool
+	config   bool
+	run      func(screen *Screen, lines lineRange, bang bool, arguments string) error
+	complete completer
+}
+
+var exCommands = []exCommand{
+	{name: "cclose", minimum: 3, run: listCloseCommand(quickfixKind)},
+	{name: "changes", minimum: 7, run: (*Screen).changesCommand},
+	{name: "cmap", minimum: 2, config: true, run: mapCommand([]int{commandMode}, true)},
+	{name: "cnext", minimum: 2, run: listMoveCommand(quickfixKind, 1)},
+	{name: "cnoremap", minimum: 3, config: true, run: mapCommand([]int{commandMode}, false)},
+	{name: "copen", minimum: 4, run: listOpenCommand(quickfixKind)},
+	{name: "cprevious", minimum: 2, run: listMoveCommand(quickfixKind, -1)},
+	{name: "cunmap", minimum: 2, config: true, run: unmapCommand([]int{commandMode})},
+	{name: "delete", minimum: 1, ranged: true, run: (*Screen).deleteCommand},
+	{name: "delmarks", minimum: 4, run: (*Screen).delmarksCommand},
+	{name: "edit", minimum: 1, run: (*Screen).editCommand, complete: completeFiles},
+	{name: "grep", minimum: 2, run: grepCommand(quickfixKind), complete: completeFiles},
+	{name: "imap", minimum: 2, config: true, run: mapCommand([]int{insertMode}, true)},
+	{name: "inoremap", minimum: 3, config: true, run: mapCommand([]int{insertMode}, false)},
+	{name: "iunmap", minimum: 2, config: true, run: unmapCommand([]int{insertMode})},
+	{name: "join", minimum: 1, ranged: true, run: (*Screen).joinCommand},
+	{name: "jumps", minimum: 2, run: (*Screen).jumpsCommand},
+	{name: "lclose", minimum: 3, run: listCloseCommand(locationKind)},
+	{name: "lgrep", minimum: 3, run: grepCommand(locationKind), complete: completeFiles},
+	{name: "lmake", minimum: 4, run: makeCommand(locationKind)},
+	{name: "lnext", minimum: 3, run: listMoveCommand(locationKind, 1)},
+	{name: "lopen", minimum: 3, run: listOpenCommand(locationKind)},
+	{name: "lprevious", minimum: 2, run: listMoveCommand(locationKind, -1)},
+	{name: "lvimgrep", minimum: 2, run: grepCommand(locationKind), complete: completeFiles},
+	{name: "make", minimum: 3, run: makeCommand(quickfixKind)},
+	{name: "map", minimum: 3, config: true, run: mapCommand([]int{normalMode, visualMode}, true)},
+	{name: "mark", minimum: 2, ranged: true, run: (*Screen).markCommand},
+	{name: "marks", minimum: 5, run: (*Screen).marksCommand},
+	{name: "nmap", minimum: 2, config: true, run: mapCommand([]int{normalMode}, true)},
+	{name: "nnoremap", minimum: 2, config: true, run: mapCommand([]int{normalMode}, false)},
+	{name: "nohlsearch", minimum: 3, run: (*Screen).nohlsearchCommand},
+	{name: "noremap", minimum: 2, config: true, run: mapCommand([]int{normalMode, visualMode}, false)},
+	{name: "nunmap", minimum: 3, config: true, run: unmapCommand([]int{normalMode})},
+	{name: "quit", minimum: 1, run: (*Screen).quitCommand},
+	{name: "retab", minimum: 3, ranged: true, run: (*Screen).retabCommand},
+	{name: "set", minimum: 2, config: true, run: (*Screen).setCommand, complete: completeOptions},
+	{name: "unmap", minimum: 3, config: true, run: unmapCommand([]int{normalMode, visualMode})},
+	{name: "vimgrep", minimum: 3, run: grepCommand(quickfixKind), complete: completeFiles},
+	{name: "vmap", minimum: 2, config: true, run: mapCommand([]int{visualMode}, true)},
+	{name: "vnoremap", minimum: 2, config: true, run: mapCommand([]int{visualMode}, false)},
+	{name: "vunmap", minimum: 2, config: true, run: unmapCommand([]int{visualMode})},
+	{name: "wq", minimum: 2, run: (*Screen).writeQuitCommand, complete: completeFiles},
+	{name: "write", minimum: 1, run: (*Screen).writeCommand, complete: completeFiles},
+	{name: "yank", minimum: 1, ranged: true, run: (*Screen).yankCommand},
+}
+
+// runCommand executes an ex command line, which is given without
+// its leading colon.
+func (screen *Screen) runCommand(line string) error {
+	line = strings.TrimLeft(line, " \t:")
+	var lines lineRange
+	if screen.file.buffer != nil {
+		var err error
+		if lines, line, err = screen.parseRange(line); err != nil {
+			return err
 		}
-		saved := screen.write()
-		if saved && action == ":wq" {
-			close(quit)
+		line = strings.TrimLeft(line, " \t")
+	}
+	nameEnd := 0
+	for nameEnd < len(line) && unicode.IsLetter(rune(line[nameEnd])) {
+		nameEnd++
+	}
+	name := line[:nameEnd]
+	bang := nameEnd < len(line) && line[nameEnd] == '!'
+	if bang {
+		nameEnd++
+	}
+	arguments := strings.TrimSpace(line[nameEnd:])
+	if name == "" && lines.addresses > 0 && arguments == "" {
+		screen.gotoLine(lines)
+		return nil
+	}
+	if name == "" {
+		return errorCommand
+	}
+	command := lookupCommand(name)
+	if command == nil {
+		return errorCommand
+	}
+	if lines.addresses > 0 && !command.ranged {
+		return noRange
+	}
+	if screen.file.buffer == nil && !command.config {
+		return invalidInConfig
+	}
+	return command.run(screen, lines, bang, arguments)
+}
+
+// lookupCommand finds the command which the name is an abbreviation of.
+func lookupCommand(name string) *exCommand {
+	for i, command := range exCommands {
+		if len(name) >= command.minimum && strings.HasPrefix(command.name, name) {
+			return &exCommands[i]
 		}
-		return
 	}
-	screen.displayError(errorCommand)
+	return nil
+}
+
+func (screen *Screen) quitCommand(lines lineRange, bang bool, arguments string) error {
+	if screen.cmdwin != nil {
+		screen.closeCommandWindow()
+		return nil
+	}
+	if !bang && !screen.file.buffer.CanSafeQuit() {
+		return modifiedFile
+	}
+	close(screen.quit)
+	return nil
+}
+
+func (screen *Screen) writeCommand(lines lineRange, bang bool, arguments string) error {
+	fileArguments := strings.Fields(arguments)
+	if len(fileArguments) > 1 {
+		return tooManyFiles
+	}
+	if len(fileArguments) == 1 {
+		screen.file.buffer.Name = fileArguments[0]
+	}
+	if screen.file.buffer.Name == "" {
+		return noFilename
+	}
+	return screen.write()
 }
 
-func (screen *Screen) write() (saved bool) {
+func (screen *Screen) writeQuitCommand(lines lineRange, bang bool, arguments string) error {
+	if screen.cmdwin != nil {
+		return invalidInCommandWindow
+	}
+	if err := screen.writeCommand(lines, bang, arguments); err != nil {
+		return err
+	}
+	close(screen.quit)
+	return nil
+}
+
+func (screen *Screen) setCommand(lines lineRange, bang bool, arguments string) error {
+	if screen.file.buffer == nil {
+		_, err := screen.options.Set(arguments)
+		return err
+	}
+	highlighting := screen.options.Bool("hlsearch")
+	message, err := screen.file.buffer.Options.Set(arguments)
+	if err != nil {
+		return err
+	}
+	if !highlighting && screen.options.Bool("hlsearch") {
+		screen.noHighlight = false
+	}
+	if message != "" {
+		screen.message = []rune(message)
+	}
+	file := screen.file.buffer
+	file.SetCursor(file.Cursor(), false)
+	screen.followCursor()
+	screen.completeDraw(nil)
+	return nil
+}
+
+func (screen *Screen) write() error {
 	err := screen.file.buffer.Save()
 	if err != nil {
-		screen.displayError(errorSave)
-		return false
+		return errorSave
 	}
+	screen.file.xCursor = screen.file.buffer.XPosition()
+	screen.completeDraw(nil)
 	screen.mode = normalMode
-	return true
+	return nil
 }
 
-func (screen *Screen) displayError(error []rune) {
+func (screen *Screen) displayError(err error) {
+	screen.fail()
 	screen.clearCommand()
-	screen.putCommand(error)
+	screen.putCommand(errorText(err))
 	screen.mode = commandErrorMode
 	screen.displayMode()
 }
+
+func errorText(err error) []rune {
+	return []rune("-- " + err.Error() + " --")
+}
+
+// deleteCommand deletes the lines of the range into the register given as
+// its first argument. A count after it deletes that many lines starting
+// with the last line of the range.
+func (screen *Screen) deleteCommand(lines lineRange, bang bool, arguments string) error {
+	lines, err := screen.registerAndCount(lines, arguments)
+	if err != nil {
+		return err
+	}
+	file := screen.file.buffer
+	screen.deleteOperator(screen.linesRegion(file.LineAt(lines.first), file.LineAt(lines.last)))
+	screen.followCursor()
+	screen.completeDraw(nil)
+	return nil
+}
+
+// joinCommand joins the lines of the range, or the line with the line
+// after it when the range is one line. With a bang, the whitespace is kept.
+func (screen *Screen) joinCommand(lines lineRange, bang bool, arguments string) error {
+	file := screen.file.buffer
+	first := file.LineAt(lines.first)
+	joinOperator(bang)(screen, screen.linesRegion(first, file.LineAt(lines.last)))
+	screen.followCursor()
+	screen.completeDraw(nil)
+	return nil
+}
+
+// retabCommand changes the whitespace with tabs in the range, or in the
+// whole file without a range, to suit the tabstop given as its argument.
+// With a bang, whitespace which is only spaces is changed too.
+func (screen *Screen) retabCommand(lines lineRange, bang bool, arguments string) error {
+	file := screen.file.buffer
+	tabSize := file.TabStop()
+	if arguments != "" {
+		number, err := strconv.Atoi(arguments)
+		if err != nil || number < 0 {
+			return errorCommand
+		}
+		if number > 0 {
+			tabSize = number
+		}
+	}
+	if lines.addresses == 0 {
+		lines = lineRange{first: 1, last: file.Lines}
+	}
+	file.Retab(file.LineAt(lines.first), file.LineAt(lines.last), tabSize, bang)
+	screen.followCursor()
+	screen.completeDraw(nil)
+	return nil
+}
+
+// yankCommand yanks the lines of the range, taking the same arguments as
+// deleteCommand.
+func (screen *Screen) yankCommand(lines lineRange, bang bool, arguments string) error {
+	lines, err := screen.registerAndCount(lines, arguments)
+	if err != nil {
+		return err
+	}
+	file := screen.file.buffer
+	text := file.LinesText(file.LineAt(lines.first), file.LineAt(lines.last))
+	screen.registers.store(screen.register, text, true, true)
+	return nil
+}
+
+func (screen *Screen) registerAndCount(lines lineRange, arguments string) (lineRange, error) {
+	screen.register = 0
+	arguments = strings.TrimSpace(arguments)
+	if arguments != "" && !unicode.IsDigit(rune(arguments[0])) {
+		name := []rune(arguments)[0]
+		if !isRegisterName(name) {
+			return lines, errorCommand
+		}
+		screen.register = name
+		arguments = strings.TrimSpace(arguments[len(string(name)):])
+	}
+	if arguments != "" {
+		count, err := strconv.Atoi(arguments)
+		if err != nil || count < 1 {
+			return lines, errorCommand
+		}
+		lines.first = lines.last
+		lines.last += count - 1
+		if lines.last > screen.file.buffer.Lines {
+			lines.last = screen.file.buffer.Lines
+		}
+	}
+	if lines.first < 1 {
+		return lines, invalidRange
+	}
+	return lines, nil
+}
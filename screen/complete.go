@@ -0,0 +1,244 @@
+package screen
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bkthomps/Ven/option"
+	"github.com/gdamore/tcell/v2"
+)
+
+// completer returns what the word being typed as the argument of an ex
+// command could be completed to.
+type completer func(screen *Screen, word string) []string
+
+// completion is the state of tab in the command line. The word from start
+// up to the cursor is replaced by each candidate in turn, and an index of
+// -1 is the word as it was typed.
+type completion struct {
+	start      int
+	typed      []rune
+	candidates []string
+	index      int
+}
+
+// commandComplete is tab or shift-tab on the command line. The first press
+// finds the candidates for the word before the cursor, and later presses
+// go through them, showing them in a menu above the command line.
+func (screen *Screen) commandComplete(forward bool) {
+	command := screen.command
+	if command.current.Data[0] != ':' {
+		screen.commandInsert('\t')
+		return
+	}
+	if command.completion == nil {
+		start, candidates := screen.completionCandidates()
+		if len(candidates) == 0 {
+			return
+		}
+		typed := append([]rune{}, command.current.Data[start:command.runeOffset]...)
+		command.completion = &completion{start: start, typed: typed, candidates: candidates, index: -1}
+		if len(candidates) == 1 {
+			screen.replaceCompleted([]rune(candidates[0]))
+			command.completion = nil
+			return
+		}
+	}
+	state := command.completion
+	if forward {
+		state.index++
+	} else {
+		state.index--
+	}
+	if state.index >= len(state.candidates) {
+		state.index = -1
+	} else if state.index < -1 {
+		state.index = len(state.candidates) - 1
+	}
+	if state.index == -1 {
+		screen.replaceCompleted(state.typed)
+		return
+	}
+	screen.replaceCompleted([]rune(state.candidates[state.index]))
+}
+
+// replaceCompleted puts the text in place of the word being completed.
+func (screen *Screen) replaceCompleted(text []rune) {
+	command := screen.command
+	data := append(append([]rune{}, command.current.Data[:command.completion.start]...), text...)
+	offset := len(data)
+	data = append(data, command.current.Data[command.runeOffset:]...)
+	command.current.Data = data
+	screen.commandMoveTo(offset)
+}
+
+// endCompletion stops going through the candidates, and removes the menu.
+func (screen *Screen) endCompletion() {
+	if screen.command.completion == nil {
+		return
+	}
+	screen.command.completion = nil
+	screen.completeDraw(nil)
+}
+
+// completionCandidates finds where the word before the cursor starts,
+// and what it could be completed to. The first word is a command name,
+// and later words are completed by the completer of the command.
+func (screen *Screen) completionCandidates() (start int, candidates []string) {
+	before := screen.command.current.Data[1:screen.command.runeOffset]
+	line := strings.TrimLeft(string(before), " \t:")
+	if screen.file.buffer != nil {
+		var err error
+		if _, line, err = screen.parseRange(line); err != nil {
+			return 0, nil
+		}
+		line = strings.TrimLeft(line, " \t")
+	}
+	nameEnd := 0
+	for nameEnd < len(line) && unicode.IsLetter(rune(line[nameEnd])) {
+		nameEnd++
+	}
+	name := line[:nameEnd]
+	rest := line[nameEnd:]
+	if rest == "" {
+		return screen.command.runeOffset - len([]rune(name)), completeCommands(name)
+	}
+	rest = strings.TrimPrefix(rest, "!")
+	if rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return 0, nil
+	}
+	found := lookupCommand(name)
+	if found == nil || found.complete == nil {
+		return 0, nil
+	}
+	word := rest[strings.LastIndexAny(rest, " \t")+1:]
+	return screen.command.runeOffset - len([]rune(word)), found.complete(screen, word)
+}
+
+func completeCommands(word string) []string {
+	candidates := make([]string, 0)
+	for _, command := range exCommands {
+		if strings.HasPrefix(command.name, word) {
+			candidates = append(candidates, command.name)
+		}
+	}
+	return candidates
+}
+
+// completeFiles completes a path relative to the working directory, where
+// directories end in a slash. Hidden files are only shown once a dot has
+// been typed.
+func completeFiles(screen *Screen, word string) []string {
+	directory, base := word[:strings.LastIndex(word, "/")+1], word[strings.LastIndex(word, "/")+1:]
+	path := directory
+	if path == "" {
+		path = "."
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	candidates := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		candidates = append(candidates, directory+name)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// completeOptions completes the name of an option, also after "no" or "inv"
+// for options which are turned on and off. After "=", it completes to the
+// current value of the option.
+func completeOptions(screen *Screen, word string) []string {
+	if strings.HasSuffix(word, "=") {
+		name := strings.TrimRight(word, "+-^=")
+		shown, err := screen.file.buffer.Options.Set(name + "?")
+		if err != nil || option.IsBool(name) {
+			return nil
+		}
+		return []string{word + shown[strings.Index(shown, "=")+1:]}
+	}
+	candidates := make([]string, 0)
+	for _, name := range option.Names() {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, name)
+		}
+	}
+	for _, prefix := range []string{"no", "inv"} {
+		if !strings.HasPrefix(word, prefix) {
+			continue
+		}
+		for _, name := range option.Names() {
+			if option.IsBool(name) && strings.HasPrefix(name, word[len(prefix):]) {
+				candidates = append(candidates, prefix+name)
+			}
+		}
+	}
+	return candidates
+}
+
+// drawWildmenu shows the candidates of the completion on the line above the
+// command line, with the chosen one highlighted. When they do not fit, the
+// menu scrolls to the chosen one, with "<" and ">" where more are hidden.
+func (screen *Screen) drawWildmenu() {
+	state := screen.command.completion
+	if state == nil {
+		return
+	}
+	y := screen.command.yPosition - 1
+	if y < 0 {
+		return
+	}
+	first := 0
+	for first < state.index && menuWidth(state.candidates[first:state.index+1], first > 0) > screen.width {
+		first++
+	}
+	x := 0
+	put := func(text string, style tcell.Style) {
+		for _, r := range text {
+			if x < screen.width {
+				screen.tCell.SetContent(x, y, r, nil, style)
+			}
+			x++
+		}
+	}
+	if first > 0 {
+		put("< ", menuStyle)
+	}
+	for i := first; i < len(state.candidates); i++ {
+		candidate := state.candidates[i]
+		if i > first && x+len([]rune(candidate)) > screen.width-2 {
+			put(">", menuStyle)
+			break
+		}
+		style := menuStyle
+		if i == state.index {
+			style = highlightStyle
+		}
+		put(candidate, style)
+		put("  ", menuStyle)
+	}
+	for x < screen.width {
+		put(" ", menuStyle)
+	}
+}
+
+func menuWidth(candidates []string, scrolled bool) int {
+	width := 0
+	if scrolled {
+		width += 2
+	}
+	for _, candidate := range candidates {
+		width += len([]rune(candidate)) + 2
+	}
+	return width
+}
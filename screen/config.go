@@ -0,0 +1,44 @@
+package screen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const configName = ".venrc"
+
+var invalidInConfig = errors.New("Invalid In Config")
+
+// loadConfig runs every line of the user configuration file as an ex
+// command. It is run before the file is loaded, so only :set and the map
+// commands are allowed. Blank lines and lines starting with a double quote
+// are ignored, and the first bad line is reported once the editor starts.
+func (screen *Screen) loadConfig() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(home, configName)
+	osFile, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer osFile.Close()
+	scanner := bufio.NewScanner(osFile)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "\"") {
+			continue
+		}
+		err := screen.runCommand(line)
+		if err != nil && screen.message == nil {
+			screen.message = errorText(fmt.Errorf("%s line %d: %w", configName, lineNumber, err))
+		}
+	}
+}
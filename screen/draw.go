@@ -1,34 +1,105 @@
 package screen
 
 import (
+	"strconv"
+
 	"github.com/bkthomps/Ven/buffer"
 	"github.com/bkthomps/Ven/search"
 )
 
-func (screen *Screen) drawLineHighlight(y int, runes []rune, instances []search.MatchInstance) {
+func (screen *Screen) drawCurrentLine() {
+	lineNumber := screen.file.buffer.LineNumber(screen.firstLine) + screen.file.yCursor
+	current := screen.file.buffer.Current
+	screen.drawFileLine(screen.file.yCursor, lineNumber, current, screen.lineHighlights(current))
+}
+
+func (screen *Screen) drawFileLine(y, lineNumber int, line *buffer.Line, instances []search.MatchInstance) {
+	y += screen.file.top
 	screen.drawBlankLine(y)
+	gutter := screen.drawGutter(y, lineNumber)
+	selectStart, selectEnd := screen.selectedOffsets(line)
+	if len(line.Data) == 0 && selectEnd > selectStart {
+		screen.tCell.SetContent(gutter, y, ' ', nil, visualStyle)
+	}
 	matchIndex := 0
 	x := 0
-	for i, r := range runes {
+	for i, r := range line.Data {
+		style := terminalStyle
+		for matchIndex < len(instances) && instances[matchIndex].Length == 0 {
+			matchIndex++
+		}
 		if matchIndex < len(instances) && i >= instances[matchIndex].StartOffset {
-			xUpdated := buffer.RuneWidthJump(r, x)
-			if r == '\t' {
-				for j := x; j < xUpdated; j++ {
-					screen.tCell.SetContent(j, y, ' ', nil, highlightStyle)
-				}
-			} else {
-				screen.tCell.SetContent(x, y, r, nil, highlightStyle)
-			}
+			style = highlightStyle
 			if i == instances[matchIndex].StartOffset+instances[matchIndex].Length-1 {
 				matchIndex++
 			}
-			x = xUpdated
-			continue
 		}
-		screen.tCell.SetContent(x, y, r, nil, terminalStyle)
-		x = buffer.RuneWidthJump(r, x)
+		if i >= selectStart && i < selectEnd {
+			style = visualStyle
+		}
+		xUpdated := buffer.RuneWidthJump(r, x, screen.file.buffer.TabStop())
+		if r == '\t' && style != terminalStyle {
+			for j := x; j < xUpdated; j++ {
+				screen.tCell.SetContent(gutter+j, y, ' ', nil, style)
+			}
+		} else {
+			screen.tCell.SetContent(gutter+x, y, r, nil, style)
+		}
+		x = xUpdated
+	}
+	if coversLineBreak(line, instances) {
+		screen.tCell.SetContent(gutter+x, y, ' ', nil, highlightStyle)
+	}
+	screen.showCursor()
+}
+
+// coversLineBreak reports whether a match reaches past the end of the
+// line onto the next one, in which case the line break is highlighted as
+// a space after the line.
+func coversLineBreak(line *buffer.Line, instances []search.MatchInstance) bool {
+	if len(instances) == 0 {
+		return false
 	}
-	screen.tCell.ShowCursor(screen.file.xCursor, screen.file.yCursor)
+	last := instances[len(instances)-1]
+	return last.StartOffset+last.Length > len(line.Data)
+}
+
+func (screen *Screen) drawGutter(y, lineNumber int) (width int) {
+	signs := screen.fileSigns()
+	width = screen.gutterWidth()
+	if width == 0 {
+		return 0
+	}
+	if text, ok := sign(signs, lineNumber); ok {
+		for i, r := range text {
+			screen.tCell.SetContent(i, y, r, nil, signStyle)
+		}
+	}
+	if !screen.options.Bool("number") {
+		return width
+	}
+	number := []rune(strconv.Itoa(lineNumber))
+	for i, r := range number {
+		screen.tCell.SetContent(width-1-len(number)+i, y, r, nil, gutterStyle)
+	}
+	return width
+}
+
+// gutterWidth is the width of the line numbers, and of the signs when the
+// file has errors from :make.
+func (screen *Screen) gutterWidth() int {
+	width := 0
+	if len(screen.fileSigns()) > 0 {
+		width = signWidth
+	}
+	if !screen.options.Bool("number") {
+		return width
+	}
+	digits := len(strconv.Itoa(screen.file.buffer.Lines))
+	if digits < minimumNumberWidth {
+		digits = minimumNumberWidth
+	}
+	return width + digits + 1
 }
 
 func (screen *Screen) drawLine(y int, runes []rune) {
@@ -36,9 +107,9 @@ func (screen *Screen) drawLine(y int, runes []rune) {
 	x := 0
 	for _, r := range runes {
 		screen.tCell.SetContent(x, y, r, nil, terminalStyle)
-		x = buffer.RuneWidthJump(r, x)
+		x = buffer.RuneWidthJump(r, x, buffer.TabSize)
 	}
-	screen.tCell.ShowCursor(screen.file.xCursor, screen.file.yCursor)
+	screen.showCursor()
 }
 
 func (screen *Screen) drawBlankLine(y int) {
@@ -46,5 +117,9 @@ func (screen *Screen) drawBlankLine(y int) {
 	for i := 0; i < screen.width; i++ {
 		screen.tCell.SetContent(i, y, ' ', nil, terminalStyle)
 	}
-	screen.tCell.ShowCursor(screen.file.xCursor, screen.file.yCursor)
+	screen.showCursor()
+}
+
+func (screen *Screen) showCursor() {
+	screen.tCell.ShowCursor(screen.gutterWidth()+screen.file.xCursor, screen.file.top+screen.file.yCursor)
 }
@@ -0,0 +1,56 @@
+package screen
+
+import (
+	"strings"
+
+	"github.com/bkthomps/Ven/buffer"
+	"github.com/bkthomps/Ven/option"
+)
+
+// loadBuffer replaces the buffer on the screen with the named file. Global
+// marks and jumps into the buffer which is left keep their line numbers so
+// that they can find their place once it is loaded again.
+func (screen *Screen) loadBuffer(fileName string) {
+	if old := screen.file.buffer; old != nil {
+		screen.forEachLocation(func(loc *location) {
+			loc.forget(old)
+		})
+	}
+	buf := &buffer.File{Options: &option.Options{}}
+	buf.Options.Init(screen.options)
+	buf.Init(fileName)
+	screen.firstLine = buf.First
+	screen.file.buffer = buf
+	screen.file.xCursor = 0
+	screen.file.yCursor = 0
+	if screen.mode == visualMode {
+		screen.mode = normalMode
+	}
+}
+
+// editCommand opens the named file, or loads the current file again when
+// no name is given. Unsaved changes are only discarded with a bang.
+func (screen *Screen) editCommand(lines lineRange, bang bool, arguments string) error {
+	fileArguments := strings.Fields(arguments)
+	if len(fileArguments) > 1 {
+		return tooManyFiles
+	}
+	if screen.cmdwin != nil {
+		return invalidInCommandWindow
+	}
+	name := screen.file.buffer.Name
+	if len(fileArguments) == 1 {
+		name = fileArguments[0]
+	}
+	if name == "" {
+		return noFilename
+	}
+	if !bang && !screen.file.buffer.CanSafeQuit() {
+		return modifiedFile
+	}
+	from := screen.here()
+	screen.loadBuffer(name)
+	screen.recordJump(from)
+	screen.completeDraw(nil)
+	return nil
+}
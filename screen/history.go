@@ -0,0 +1,129 @@
+package screen
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const historyName = ".ven_history"
+
+// history holds the commands or searches which were run, oldest first,
+// without the ":" or "/" before them.
+type history []string
+
+// recall is the state of going through the history with up and down. Only
+// entries which start with what was typed before the first up are shown.
+type recall struct {
+	index int
+	typed []rune
+}
+
+// historyKind is the history which a command line uses, where "/" and "?"
+// share the search history.
+func historyKind(r rune) rune {
+	if r == '?' {
+		return '/'
+	}
+	return r
+}
+
+func historyPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, historyName), nil
+}
+
+// loadHistory reads the history of previous sessions, where each line
+// is the kind of history followed by the entry, such as ":w" or "/foo".
+func (screen *Screen) loadHistory() {
+	screen.histories = map[rune]history{':': nil, '/': nil}
+	path, err := historyPath()
+	if err != nil {
+		return
+	}
+	osFile, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer osFile.Close()
+	scanner := bufio.NewScanner(osFile)
+	for scanner.Scan() {
+		line := []rune(scanner.Text())
+		if len(line) < 2 {
+			continue
+		}
+		if entries, ok := screen.histories[line[0]]; ok {
+			screen.histories[line[0]] = append(entries, string(line[1:]))
+		}
+	}
+}
+
+func (screen *Screen) saveHistory() {
+	path, err := historyPath()
+	if err != nil {
+		return
+	}
+	var builder strings.Builder
+	for _, kind := range []rune{':', '/'} {
+		for _, entry := range screen.histories[kind] {
+			builder.WriteRune(kind)
+			builder.WriteString(entry)
+			builder.WriteRune('\n')
+		}
+	}
+	_ = os.WriteFile(path, []byte(builder.String()), 0600)
+}
+
+// addHistory puts the entry at the end of its history, removing an older
+// copy of it, and keeps only as many entries as the history option.
+func (screen *Screen) addHistory(kind rune, entry string) {
+	kind = historyKind(kind)
+	entries, ok := screen.histories[kind]
+	if !ok || strings.TrimSpace(entry) == "" {
+		return
+	}
+	kept := make(history, 0, len(entries)+1)
+	for _, existing := range entries {
+		if existing != entry {
+			kept = append(kept, existing)
+		}
+	}
+	kept = append(kept, entry)
+	if size := screen.options.Number("history"); len(kept) > size {
+		kept = kept[len(kept)-size:]
+	}
+	screen.histories[kind] = kept
+	screen.saveHistory()
+}
+
+// commandRecall is up or down on the command line, which shows the next
+// older or newer entry starting with the typed text. Going past the
+// newest entry shows the typed text again.
+func (screen *Screen) commandRecall(older bool) {
+	command := screen.command
+	entries := screen.histories[historyKind(command.current.Data[0])]
+	if command.recall == nil {
+		typed := append([]rune{}, command.current.Data[1:]...)
+		command.recall = &recall{index: len(entries), typed: typed}
+	}
+	state := command.recall
+	step := 1
+	if older {
+		step = -1
+	}
+	for i := state.index + step; i >= 0 && i < len(entries); i += step {
+		if strings.HasPrefix(entries[i], string(state.typed)) {
+			state.index = i
+			screen.setCommandText([]rune(entries[i]))
+			return
+		}
+	}
+	if !older && state.index < len(entries) {
+		state.index = len(entries)
+		screen.setCommandText(state.typed)
+	}
+}
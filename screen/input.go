@@ -0,0 +1,245 @@
+package screen
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bkthomps/Ven/keymap"
+	"github.com/gdamore/tcell/v2"
+)
+
+const maxMapDepth = 1000
+
+var (
+	recursiveMapping = errors.New("Recursive Mapping")
+	noMapping        = errors.New("No Such Mapping")
+)
+
+// binding connects a sequence of keys, written in vim key notation,
+// to the built-in action which it performs.
+type binding struct {
+	keys   string
+	action func(screen *Screen)
+}
+
+type bindings map[string]func(screen *Screen)
+
+func newBindings(list []binding) bindings {
+	table := make(bindings, len(list))
+	for _, b := range list {
+		table[keymap.Format(keymap.Parse(b.keys, nil))] = b.action
+	}
+	return table
+}
+
+// lookup finds the action bound to the keys, and reports whether the
+// keys are the start of a longer binding.
+func (table bindings) lookup(keys []keymap.Key) (action func(screen *Screen), isPrefix bool) {
+	typed := keymap.Format(keys)
+	for bound := range table {
+		if len(bound) > len(typed) && strings.HasPrefix(bound, typed) {
+			isPrefix = true
+			break
+		}
+	}
+	return table[typed], isPrefix
+}
+
+type input struct {
+	typeahead  []typedKey
+	generation int
+	recording  rune
+	recorded   []keymap.Key
+	lastMacro  rune
+}
+
+// typedKey is a key waiting to be dispatched. Keys which came from the
+// right-hand side of a non-recursive mapping cannot be remapped.
+type typedKey struct {
+	key     keymap.Key
+	noremap bool
+}
+
+type timeoutEvent struct {
+	tcell.EventTime
+	generation int
+}
+
+func (screen *Screen) typeKey(key keymap.Key) {
+	if screen.input.recording != 0 {
+		screen.input.recorded = append(screen.input.recorded, key)
+	}
+	screen.input.typeahead = append(screen.input.typeahead, typedKey{key: key})
+	screen.resolveTypeahead(false)
+}
+
+// resolveTypeahead applies the user mappings of the current mode to the
+// typed keys and dispatches the result. When the typed keys could still
+// become a longer mapping, it waits for more keys until timeoutlen has
+// passed, after which the longest complete mapping is used.
+func (screen *Screen) resolveTypeahead(timedOut bool) {
+	screen.input.generation++
+	depth := 0
+	for len(screen.input.typeahead) > 0 {
+		typeahead := screen.input.typeahead
+		remappable := make([]keymap.Key, 0, len(typeahead))
+		for _, typed := range typeahead {
+			if typed.noremap {
+				break
+			}
+			remappable = append(remappable, typed.key)
+		}
+		var match *keymap.Mapping
+		ambiguous := false
+		if keyMap := screen.modeMappings(); keyMap != nil && len(remappable) > 0 {
+			match, ambiguous = keyMap.Lookup(remappable)
+		}
+		if ambiguous && !timedOut {
+			screen.startTimeout()
+			return
+		}
+		timedOut = false
+		if match == nil {
+			screen.input.typeahead = typeahead[1:]
+			screen.dispatch(typeahead[0].key)
+			continue
+		}
+		depth++
+		if depth > maxMapDepth {
+			screen.input.typeahead = nil
+			screen.message = errorText(recursiveMapping)
+			return
+		}
+		rest := typeahead[len(match.From):]
+		expanded := make([]typedKey, 0, len(match.To)+len(rest))
+		startsWithFrom := keymap.HasPrefix(match.To, match.From)
+		for i, key := range match.To {
+			noremap := !match.Recursive || (startsWithFrom && i < len(match.From))
+			expanded = append(expanded, typedKey{key: key, noremap: noremap})
+		}
+		screen.input.typeahead = append(expanded, rest...)
+	}
+}
+
+// fail discards the keys which have not been dispatched yet, as vim
+// does when a command fails, which also stops a macro from playing.
+func (screen *Screen) fail() {
+	screen.input.typeahead = nil
+}
+
+func (screen *Screen) startTimeout() {
+	generation := screen.input.generation
+	wait := time.Duration(screen.options.Number("timeoutlen")) * time.Millisecond
+	time.AfterFunc(wait, func() {
+		ev := &timeoutEvent{generation: generation}
+		ev.SetEventNow()
+		_ = screen.tCell.PostEvent(ev)
+	})
+}
+
+func (screen *Screen) modeMappings() *keymap.Map {
+	switch screen.mode {
+	case normalMode, visualMode:
+		if screen.pending.awaitingRegister || screen.pending.awaiting != nil {
+			return nil
+		}
+	case highlightMode:
+		return screen.mappings[normalMode]
+	case replaceMode:
+		return screen.mappings[insertMode]
+	case commandMode:
+		if screen.command.awaitingRegister {
+			return nil
+		}
+	case commandErrorMode:
+		return nil
+	}
+	return screen.mappings[screen.mode]
+}
+
+func (screen *Screen) leader() []keymap.Key {
+	return keymap.Parse(screen.options.String("mapleader"), nil)
+}
+
+func mapCommand(modes []int, recursive bool) func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+	return func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+		targets := modes
+		if bang {
+			targets = []int{insertMode, commandMode}
+		}
+		from, to := splitMapArguments(arguments)
+		if to == "" {
+			screen.listMappings(targets, keymap.Parse(from, screen.leader()))
+			return nil
+		}
+		fromKeys := keymap.Parse(from, screen.leader())
+		if len(fromKeys) == 0 {
+			return errorCommand
+		}
+		toKeys := keymap.Parse(to, screen.leader())
+		for _, mode := range targets {
+			screen.mappings[mode].Add(fromKeys, toKeys, recursive)
+		}
+		return nil
+	}
+}
+
+func unmapCommand(modes []int) func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+	return func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+		targets := modes
+		if bang {
+			targets = []int{insertMode, commandMode}
+		}
+		from, _ := splitMapArguments(arguments)
+		fromKeys := keymap.Parse(from, screen.leader())
+		removed := false
+		for _, mode := range targets {
+			if screen.mappings[mode].Remove(fromKeys) {
+				removed = true
+			}
+		}
+		if !removed {
+			return noMapping
+		}
+		return nil
+	}
+}
+
+func splitMapArguments(arguments string) (from, to string) {
+	arguments = strings.TrimSpace(arguments)
+	index := strings.IndexAny(arguments, " \t")
+	if index < 0 {
+		return arguments, ""
+	}
+	return arguments[:index], strings.TrimLeft(arguments[index:], " \t")
+}
+
+var modeNames = map[int]string{
+	normalMode:  "n",
+	insertMode:  "i",
+	commandMode: "c",
+	visualMode:  "v",
+}
+
+func (screen *Screen) listMappings(modes []int, prefix []keymap.Key) {
+	listed := make([]string, 0)
+	for _, mode := range modes {
+		for _, mapping := range screen.mappings[mode].Mappings() {
+			if !keymap.HasPrefix(mapping.From, prefix) {
+				continue
+			}
+			recursive := "* "
+			if mapping.Recursive {
+				recursive = ""
+			}
+			entry := modeNames[mode] + " " + keymap.Format(mapping.From) + " " + recursive + keymap.Format(mapping.To)
+			listed = append(listed, entry)
+		}
+	}
+	if len(listed) == 0 {
+		screen.message = []rune("No mapping found")
+		return
+	}
+	screen.message = []rune(strings.Join(listed, " | "))
+}
@@ -0,0 +1,79 @@
+package screen
+
+import (
+	"unicode"
+
+	"github.com/bkthomps/Ven/keymap"
+)
+
+func isMacroRegister(r rune) bool {
+	return r == unnamedRegister || (r >= '0' && r <= '9') || (unicode.IsLetter(r) && r < unicode.MaxASCII)
+}
+
+// record is "q", which starts recording typed keys into the register
+// given as its argument, or stops the recording if there is one. "q:",
+// "q/" and "q?" open the command-line window instead.
+func (screen *Screen) record(count int) {
+	if screen.input.recording != 0 {
+		screen.stopRecording()
+		return
+	}
+	if kind := screen.argument; kind == ':' || kind == '/' || kind == '?' {
+		screen.openCommandWindow(kind, nil)
+		return
+	}
+	if !isMacroRegister(screen.argument) {
+		screen.fail()
+		return
+	}
+	screen.input.recording = screen.argument
+	screen.input.recorded = nil
+}
+
+// stopRecording keeps the macro in its register as vim key notation, such
+// as "dw<Esc>", so that it can be put into the file, edited, and yanked
+// back. The "q" which stopped the recording is left out.
+func (screen *Screen) stopRecording() {
+	recorded := screen.input.recorded
+	if len(recorded) > 0 {
+		recorded = recorded[:len(recorded)-1]
+	}
+	screen.registers.record(screen.input.recording, []rune(keymap.Format(recorded)))
+	screen.input.recording = 0
+	screen.input.recorded = nil
+}
+
+// playMacro is "@", which types the keys in a register count times. As
+// the keys go through typeahead, a failing command stops the macro.
+func (screen *Screen) playMacro(count int) {
+	name := screen.argument
+	if name == '@' {
+		name = screen.input.lastMacro
+	}
+	if !isMacroRegister(name) {
+		screen.fail()
+		return
+	}
+	stored, err := screen.registers.load(name)
+	if err != nil {
+		screen.message = errorText(err)
+		screen.fail()
+		return
+	}
+	screen.input.lastMacro = name
+	keys := keymap.Parse(string(stored.text), nil)
+	played := make([]typedKey, 0, len(keys)*counted(count)+len(screen.input.typeahead))
+	for i := 0; i < counted(count); i++ {
+		for _, key := range keys {
+			played = append(played, typedKey{key: key})
+		}
+	}
+	screen.input.typeahead = append(played, screen.input.typeahead...)
+}
+
+func (screen *Screen) recordingText() []rune {
+	if screen.input.recording == 0 {
+		return nil
+	}
+	return []rune("recording @" + string(screen.input.recording))
+}
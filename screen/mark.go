@@ -0,0 +1,454 @@
+package screen
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bkthomps/Ven/buffer"
+)
+
+var (
+	markNotSet   = errors.New("Mark Not Set")
+	noChanges    = errors.New("Change List Is Empty")
+	oldestChange = errors.New("At Start Of Change List")
+	newestChange = errors.New("At End Of Change List")
+)
+
+const maxJumps = 100
+
+// location is a position in a file which may not be loaded. The line and
+// offset are kept up to date when the file is left, so that the position
+// can be found again when the file is loaded.
+type location struct {
+	fileName string
+	file     *buffer.File
+	position buffer.Position
+	line     int
+	offset   int
+}
+
+// jumpList holds where the cursor was before each jump, which ctrl-o and
+// ctrl-i go back and forth through. The index is the entry which ctrl-o
+// returned to, or the length of the list when it has not been used.
+type jumpList struct {
+	locations []*location
+	index     int
+	previous  *location
+}
+
+func (screen *Screen) here() location {
+	file := screen.file.buffer
+	cursor := file.Cursor()
+	return location{
+		fileName: file.Name,
+		file:     file,
+		position: cursor,
+		line:     file.LineNumber(cursor.Line),
+		offset:   cursor.Offset,
+	}
+}
+
+func sameFile(a, b string) bool {
+	absoluteA, errA := filepath.Abs(a)
+	absoluteB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return absoluteA == absoluteB
+}
+
+// forget keeps the line number of a location in a file which is being
+// left, or clears it if its line was removed.
+func (loc *location) forget(file *buffer.File) {
+	if loc.file != file {
+		return
+	}
+	loc.line = 0
+	if file.Contains(loc.position.Line) {
+		loc.line = file.LineNumber(loc.position.Line)
+		loc.offset = loc.position.Offset
+	}
+}
+
+func (loc *location) sameLine(other *location) bool {
+	if loc.file != nil && loc.file == other.file {
+		return loc.position.Line == other.position.Line
+	}
+	return loc.line == other.line && sameFile(loc.fileName, other.fileName)
+}
+
+// resolve finds the position of the location in the current file. When
+// load is true, the file of the location is loaded if it is another one.
+func (screen *Screen) resolve(loc *location, load bool) (buffer.Position, error) {
+	file := screen.file.buffer
+	if loc.file != file {
+		if loc.line == 0 {
+			return buffer.Position{}, markNotSet
+		}
+		if !sameFile(loc.fileName, file.Name) {
+			if !load {
+				return buffer.Position{}, markNotSet
+			}
+			if screen.cmdwin != nil {
+				return buffer.Position{}, invalidInCommandWindow
+			}
+			if !file.CanSafeQuit() {
+				return buffer.Position{}, modifiedFile
+			}
+			screen.loadBuffer(loc.fileName)
+			file = screen.file.buffer
+		}
+		loc.file = file
+		loc.position = buffer.Position{Line: file.LineAt(loc.line), Offset: loc.offset}
+	}
+	if !file.Contains(loc.position.Line) {
+		return buffer.Position{}, markNotSet
+	}
+	return loc.position, nil
+}
+
+func (screen *Screen) forEachLocation(visit func(loc *location)) {
+	for _, loc := range screen.globalMarks {
+		visit(loc)
+	}
+	for _, loc := range screen.jumps.locations {
+		visit(loc)
+	}
+	if screen.jumps.previous != nil {
+		visit(screen.jumps.previous)
+	}
+}
+
+// dropLocations removes the global marks and jumps into a buffer which is
+// gone without a file to find them in again.
+func (screen *Screen) dropLocations(file *buffer.File) {
+	for name, loc := range screen.globalMarks {
+		if loc.file == file {
+			delete(screen.globalMarks, name)
+		}
+	}
+	kept := make([]*location, 0, len(screen.jumps.locations))
+	for _, loc := range screen.jumps.locations {
+		if loc.file != file {
+			kept = append(kept, loc)
+		}
+	}
+	screen.jumps.locations = kept
+	screen.jumps.index = len(kept)
+	if screen.jumps.previous != nil && screen.jumps.previous.file == file {
+		screen.jumps.previous = nil
+	}
+}
+
+func isLocalMark(name rune) bool {
+	return name >= 'a' && name <= 'z'
+}
+
+func isGlobalMark(name rune) bool {
+	return name >= 'A' && name <= 'Z'
+}
+
+// setMark is "m", which sets the mark given as its argument to the cursor.
+// Lowercase marks belong to the file, while uppercase marks are global.
+func (screen *Screen) setMark(count int) {
+	if !screen.setMarkAt(screen.argument, screen.here()) {
+		screen.fail()
+	}
+}
+
+func (screen *Screen) setMarkAt(name rune, at location) bool {
+	switch {
+	case isLocalMark(name):
+		screen.file.buffer.SetMark(name, at.position)
+	case isGlobalMark(name):
+		screen.globalMarks[name] = &at
+	case name == '\'' || name == '`':
+		screen.recordJump(at)
+	default:
+		return false
+	}
+	return true
+}
+
+// markPosition finds the position of a mark, which is a lowercase or
+// uppercase letter, "<" or ">" for the last visual selection, "." for the
+// last change, or "'" or "`" for the position before the last jump. When load is true, the file
+// of an uppercase mark is loaded if it is another one.
+func (screen *Screen) markPosition(name rune, load bool) (buffer.Position, error) {
+	switch {
+	case isLocalMark(name) || name == '<' || name == '>':
+		position, ok := screen.file.buffer.Mark(name)
+		if !ok {
+			return buffer.Position{}, markNotSet
+		}
+		return position, nil
+	case isGlobalMark(name):
+		if loc, ok := screen.globalMarks[name]; ok {
+			return screen.resolve(loc, load)
+		}
+	case name == '.':
+		if position, ok := screen.file.buffer.LastChange(); ok {
+			return position, nil
+		}
+	case name == '\'' || name == '`':
+		if screen.jumps.previous != nil {
+			return screen.resolve(screen.jumps.previous, load)
+		}
+	}
+	return buffer.Position{}, markNotSet
+}
+
+// markMotion is "`" followed by a mark, which goes to the position of
+// the mark, or "'" when not exact, which goes to the first non-blank rune
+// of its line. A mark in another file is only followed when not operating.
+func markMotion(exact bool) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		origin := screen.file.buffer.Cursor()
+		position, err := screen.markPosition(screen.argument, !operating)
+		if err != nil {
+			screen.message = errorText(err)
+			return origin, exclusive, false
+		}
+		if exact {
+			return position, exclusive, true
+		}
+		return buffer.Position{Line: position.Line, Offset: position.Line.FirstNonBlank()}, linewise, true
+	}
+}
+
+// recordJump adds the location to the jump list, removing any older entry
+// on the same line, and makes it the position before the last jump.
+func (screen *Screen) recordJump(from location) {
+	jumps := &screen.jumps
+	kept := make([]*location, 0, len(jumps.locations)+1)
+	for _, loc := range jumps.locations {
+		if !loc.sameLine(&from) {
+			kept = append(kept, loc)
+		}
+	}
+	kept = append(kept, &from)
+	if len(kept) > maxJumps {
+		kept = kept[len(kept)-maxJumps:]
+	}
+	jumps.locations = kept
+	jumps.index = len(kept)
+	previous := from
+	jumps.previous = &previous
+}
+
+// jumpOlder is ctrl-o, which goes back through the jump list. The first
+// time, the cursor is added to the list so that ctrl-i can return to it.
+func (screen *Screen) jumpOlder(count int) {
+	jumps := &screen.jumps
+	if jumps.index == len(jumps.locations) {
+		screen.recordJump(screen.here())
+		jumps.index = len(jumps.locations) - 1
+	}
+	screen.jumpTo(jumps.index - counted(count))
+}
+
+// jumpNewer is ctrl-i or tab, which goes forward through the jump list.
+func (screen *Screen) jumpNewer(count int) {
+	screen.jumpTo(screen.jumps.index + counted(count))
+}
+
+func (screen *Screen) jumpTo(index int) {
+	jumps := &screen.jumps
+	if index < 0 || index >= len(jumps.locations) {
+		screen.fail()
+		return
+	}
+	file := screen.file.buffer
+	position, err := screen.resolve(jumps.locations[index], true)
+	if err != nil {
+		jumps.locations = append(jumps.locations[:index], jumps.locations[index+1:]...)
+		if jumps.index > index {
+			jumps.index--
+		}
+		screen.message = errorText(err)
+		screen.fail()
+		return
+	}
+	jumps.index = index
+	screen.file.buffer.SetCursor(position, false)
+	screen.followCursor()
+	if file != screen.file.buffer {
+		screen.completeDraw(nil)
+	}
+}
+
+// stepChange is "g;" when older, which goes back to where the file was
+// changed, or "g," which goes forward through the change list.
+func stepChange(older bool) func(screen *Screen, count int) {
+	return func(screen *Screen, count int) {
+		file := screen.file.buffer
+		steps := counted(count)
+		if older {
+			steps = -steps
+		}
+		position, ok := file.StepChange(steps)
+		if !ok {
+			err := newestChange
+			if positions, _ := file.Changes(); len(positions) == 0 {
+				err = noChanges
+			} else if older {
+				err = oldestChange
+			}
+			screen.message = errorText(err)
+			screen.fail()
+			return
+		}
+		file.SetCursor(position, false)
+	}
+}
+
+// setVisualMarks sets the "<" and ">" marks to the start and end of the
+// selection which visual mode is leaving.
+func (screen *Screen) setVisualMarks() {
+	start, end := screen.visual.anchor, screen.file.buffer.Cursor()
+	if screen.file.buffer.Compare(start, end) > 0 {
+		start, end = end, start
+	}
+	if screen.visual.linewise {
+		start.Offset = 0
+		end.Offset = len(end.Line.Data)
+	}
+	screen.file.buffer.SetMark('<', start)
+	screen.file.buffer.SetMark('>', end)
+}
+
+func (screen *Screen) markCommand(lines lineRange, bang bool, arguments string) error {
+	name := []rune(arguments)
+	if len(name) != 1 {
+		return errorCommand
+	}
+	at := screen.here()
+	at.position = buffer.Position{Line: screen.file.buffer.LineAt(lines.last)}
+	at.line = lines.last
+	at.offset = 0
+	if !screen.setMarkAt(name[0], at) {
+		return errorCommand
+	}
+	return nil
+}
+
+// marksCommand lists the marks which are set, or only the given ones.
+func (screen *Screen) marksCommand(lines lineRange, bang bool, arguments string) error {
+	file := screen.file.buffer
+	names := file.MarkNames()
+	for name := 'A'; name <= 'Z'; name++ {
+		if _, ok := screen.globalMarks[name]; ok {
+			names = append(names, name)
+		}
+	}
+	if _, ok := file.LastChange(); ok {
+		names = append([]rune{'.'}, names...)
+	}
+	if screen.jumps.previous != nil {
+		names = append([]rune{'\''}, names...)
+	}
+	listed := make([]string, 0)
+	for _, name := range names {
+		if arguments != "" && !strings.ContainsRune(arguments, name) {
+			continue
+		}
+		entry := string(name) + " "
+		if loc, ok := screen.globalMarks[name]; ok && !sameFile(loc.fileName, file.Name) {
+			entry += strconv.Itoa(loc.line) + " " + loc.fileName
+		} else if position, err := screen.markPosition(name, false); err == nil {
+			entry += strconv.Itoa(file.LineNumber(position.Line)) + " " + strings.TrimSpace(string(position.Line.Data))
+		} else {
+			continue
+		}
+		listed = append(listed, entry)
+	}
+	if len(listed) == 0 {
+		return markNotSet
+	}
+	screen.message = []rune(strings.Join(listed, " | "))
+	return nil
+}
+
+// delmarksCommand deletes the given marks, such as "a b" or "a-d", or
+// every lowercase mark with a bang.
+func (screen *Screen) delmarksCommand(lines lineRange, bang bool, arguments string) error {
+	if bang {
+		for _, name := range screen.file.buffer.MarkNames() {
+			if isLocalMark(name) {
+				screen.file.buffer.DeleteMark(name)
+			}
+		}
+		return nil
+	}
+	names := []rune(strings.ReplaceAll(arguments, " ", ""))
+	if len(names) == 0 {
+		return errorCommand
+	}
+	for i := 0; i < len(names); i++ {
+		first, last := names[i], names[i]
+		if i+2 < len(names) && names[i+1] == '-' {
+			last = names[i+2]
+			i += 2
+		}
+		for name := first; name <= last; name++ {
+			switch {
+			case isGlobalMark(name):
+				delete(screen.globalMarks, name)
+			case isLocalMark(name) || name == '<' || name == '>':
+				screen.file.buffer.DeleteMark(name)
+			default:
+				return errorCommand
+			}
+		}
+	}
+	return nil
+}
+
+// changesCommand lists the change list, with ">" at the entry which "g;"
+// or "g," last went to.
+func (screen *Screen) changesCommand(lines lineRange, bang bool, arguments string) error {
+	file := screen.file.buffer
+	positions, index := file.Changes()
+	if len(positions) == 0 {
+		return noChanges
+	}
+	listed := make([]string, 0, len(positions))
+	for i, position := range positions {
+		entry := strconv.Itoa(len(positions)-i) + " " + strconv.Itoa(file.LineNumber(position.Line)) +
+			" " + strconv.Itoa(position.Offset)
+		if i == index {
+			entry = ">" + entry
+		}
+		listed = append(listed, entry)
+	}
+	screen.message = []rune(strings.Join(listed, " | "))
+	return nil
+}
+
+// jumpsCommand lists the jump list, with ">" at the current entry.
+func (screen *Screen) jumpsCommand(lines lineRange, bang bool, arguments string) error {
+	jumps := &screen.jumps
+	listed := make([]string, 0, len(jumps.locations))
+	for i, loc := range jumps.locations {
+		line := loc.line
+		if loc.file == screen.file.buffer && loc.file.Contains(loc.position.Line) {
+			line = loc.file.LineNumber(loc.position.Line)
+		}
+		entry := strconv.Itoa(len(jumps.locations)-i) + " " + strconv.Itoa(line)
+		if !sameFile(loc.fileName, screen.file.buffer.Name) {
+			entry += " " + loc.fileName
+		}
+		if i == jumps.index {
+			entry = ">" + entry
+		}
+		listed = append(listed, entry)
+	}
+	if len(listed) == 0 {
+		screen.message = []rune("No jumps")
+		return nil
+	}
+	screen.message = []rune(strings.Join(listed, " | "))
+	return nil
+}
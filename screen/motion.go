@@ -0,0 +1,320 @@
+package screen
+
+import "github.com/bkthomps/Ven/buffer"
+
+type motionKind int
+
+const (
+	exclusive motionKind = iota
+	inclusive
+	linewise
+)
+
+// motion finds where the cursor would move to, and reports whether the
+// move was possible. A motion may move the cursor while it searches,
+// since the caller always places the cursor afterwards. When operating
+// is true, the motion is the target of an operator such as "d".
+type motion func(screen *Screen, count int, operating bool) (target buffer.Position, kind motionKind, ok bool)
+
+func counted(count int) int {
+	if count < 1 {
+		return 1
+	}
+	return count
+}
+
+func leftMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	position := screen.file.buffer.Cursor()
+	if position.Offset == 0 {
+		return position, exclusive, false
+	}
+	position.Offset -= counted(count)
+	if position.Offset < 0 {
+		position.Offset = 0
+	}
+	return position, exclusive, true
+}
+
+func rightMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	position := screen.file.buffer.Cursor()
+	limit := len(position.Line.Data) - 1
+	if operating {
+		limit++
+	}
+	if position.Offset >= limit {
+		return position, exclusive, false
+	}
+	position.Offset += counted(count)
+	if position.Offset > limit {
+		position.Offset = limit
+	}
+	return position, exclusive, true
+}
+
+func downMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	moved := false
+	for i := 0; i < counted(count); i++ {
+		possible, _ := screen.file.buffer.Down(false)
+		if !possible {
+			break
+		}
+		moved = true
+	}
+	return screen.file.buffer.Cursor(), linewise, moved
+}
+
+func upMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	moved := false
+	for i := 0; i < counted(count); i++ {
+		possible, _ := screen.file.buffer.Up(false)
+		if !possible {
+			break
+		}
+		moved = true
+	}
+	return screen.file.buffer.Cursor(), linewise, moved
+}
+
+func startOfLineMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	return buffer.Position{Line: screen.file.buffer.Current}, exclusive, true
+}
+
+func endOfLineMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	line := screen.file.buffer.Current
+	for i := 1; i < counted(count); i++ {
+		if line.Next == nil {
+			return screen.file.buffer.Cursor(), inclusive, false
+		}
+		line = line.Next
+	}
+	offset := len(line.Data) - 1
+	if offset < 0 {
+		offset = 0
+	}
+	return buffer.Position{Line: line, Offset: offset}, inclusive, true
+}
+
+// wordMotions are the moves of the buffer for words, or for WORDs.
+type wordMotions struct {
+	bigWord   bool
+	nextStart func(file *buffer.File) (int, int)
+	prevStart func(file *buffer.File) (int, int)
+	nextEnd   func(file *buffer.File) (int, int)
+}
+
+var (
+	words    = wordMotions{false, (*buffer.File).NextWordStart, (*buffer.File).PrevWordStart, (*buffer.File).NextWordEnd}
+	bigWords = wordMotions{true, (*buffer.File).NextBigWordStart, (*buffer.File).PrevBigWordStart, (*buffer.File).NextBigWordEnd}
+)
+
+// nextWordStartMotion is "w" or "W". As in vim, when it is the target of
+// an operator, it stops at the end of the last word it moves over instead
+// of moving onto the next line, and at the end of the file it includes
+// the last rune.
+func nextWordStartMotion(moves wordMotions) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		file := screen.file.buffer
+		origin := file.Cursor()
+		lastStart := origin
+		for i := 0; i < counted(count); i++ {
+			lastStart = file.Cursor()
+			moves.nextStart(file)
+		}
+		target := file.Cursor()
+		if !operating {
+			return target, exclusive, target != origin
+		}
+		if target.Line != lastStart.Line {
+			return buffer.Position{Line: lastStart.Line, Offset: len(lastStart.Line.Data)}, exclusive, true
+		}
+		isLastRune := target.Line.Next == nil && target.Offset == len(target.Line.Data)-1
+		if isLastRune && (target == lastStart || !moves.isStart(file, target)) {
+			target.Offset++
+		}
+		return target, exclusive, true
+	}
+}
+
+func (moves wordMotions) isStart(file *buffer.File, position buffer.Position) bool {
+	if file.IsBlank(position) {
+		return false
+	}
+	class := file.Class(position, moves.bigWord)
+	previous := buffer.Position{Line: position.Line, Offset: position.Offset - 1}
+	return position.Offset == 0 || file.Class(previous, moves.bigWord) != class
+}
+
+func (moves wordMotions) isEnd(file *buffer.File, position buffer.Position) bool {
+	class := file.Class(position, moves.bigWord)
+	next := buffer.Position{Line: position.Line, Offset: position.Offset + 1}
+	return !file.IsBlank(position) && file.Class(next, moves.bigWord) != class
+}
+
+func prevWordStartMotion(moves wordMotions) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		file := screen.file.buffer
+		origin := file.Cursor()
+		for i := 0; i < counted(count); i++ {
+			moves.prevStart(file)
+		}
+		return file.Cursor(), exclusive, file.Cursor() != origin
+	}
+}
+
+func nextWordEndMotion(moves wordMotions) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		file := screen.file.buffer
+		origin := file.Cursor()
+		for i := 0; i < counted(count); i++ {
+			moves.nextEnd(file)
+		}
+		return file.Cursor(), inclusive, file.Cursor() != origin
+	}
+}
+
+// changeWordMotion is "w" or "W" after "c", which as in vim acts like "e"
+// when the cursor is on a word, so the space after the word is kept.
+func changeWordMotion(moves wordMotions) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		file := screen.file.buffer
+		cursor := file.Cursor()
+		if file.IsBlank(cursor) {
+			return nextWordStartMotion(moves)(screen, count, operating)
+		}
+		if moves.isEnd(file, cursor) {
+			if count <= 1 {
+				return cursor, inclusive, true
+			}
+			count--
+		}
+		return nextWordEndMotion(moves)(screen, count, operating)
+	}
+}
+
+// repeatedMotion is a motion which moves the cursor count times with a
+// move of the buffer, such as "}" for the next paragraph. When it is the
+// target of an operator and stops at the end of the file, the last rune
+// is included.
+func repeatedMotion(move func(file *buffer.File) (int, int)) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		file := screen.file.buffer
+		origin := file.Cursor()
+		for i := 0; i < counted(count); i++ {
+			move(file)
+		}
+		target := file.Cursor()
+		isLastRune := target.Line.Next == nil && target.Offset == len(target.Line.Data)-1
+		if operating && isLastRune && file.Compare(origin, target) < 0 {
+			target.Offset++
+		}
+		return target, exclusive, target != origin
+	}
+}
+
+// matchBracketMotion is "%", which goes to the matching bracket, or with
+// a count, goes to that percentage of the file.
+func matchBracketMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	file := screen.file.buffer
+	if count > 100 {
+		return file.Cursor(), linewise, false
+	}
+	if count > 0 {
+		return buffer.Position{Line: file.LineAt((count*file.Lines + 99) / 100)}, linewise, true
+	}
+	_, _, found := file.MatchBracket()
+	return file.Cursor(), inclusive, found
+}
+
+// characterFind is the last character search on a line, which ";" and "," repeat.
+type characterFind struct {
+	target  rune
+	forward bool
+	till    bool
+}
+
+// findMotion is "f", "F", "t" or "T", which search the line for the
+// character typed after them.
+func findMotion(forward, till bool) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		screen.lastFind = &characterFind{target: screen.argument, forward: forward, till: till}
+		return screen.lastFind.move(screen, count, false)
+	}
+}
+
+// repeatFindMotion is ";", or "," when reversed.
+func repeatFindMotion(reversed bool) motion {
+	return func(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+		if screen.lastFind == nil {
+			return screen.file.buffer.Cursor(), exclusive, false
+		}
+		repeated := *screen.lastFind
+		if reversed {
+			repeated.forward = !repeated.forward
+		}
+		return repeated.move(screen, count, true)
+	}
+}
+
+// move performs the search. As in vim, a repeated "t" or "T" does not
+// get stuck on the character which it stopped before.
+func (find characterFind) move(screen *Screen, count int, repeated bool) (buffer.Position, motionKind, bool) {
+	file := screen.file.buffer
+	origin := file.Cursor()
+	if !find.forward {
+		if repeated && find.till && origin.Offset > 1 && origin.Line.Data[origin.Offset-1] == find.target {
+			file.SetCursor(buffer.Position{Line: origin.Line, Offset: origin.Offset - 1}, false)
+		}
+		_, found := file.FindBackward(find.target, counted(count), find.till)
+		return file.Cursor(), exclusive, found
+	}
+	if repeated && find.till && origin.Offset+1 < len(origin.Line.Data) && origin.Line.Data[origin.Offset+1] == find.target {
+		file.SetCursor(buffer.Position{Line: origin.Line, Offset: origin.Offset + 1}, false)
+	}
+	_, found := file.FindForward(find.target, counted(count), find.till)
+	return file.Cursor(), inclusive, found
+}
+
+// firstLineMotion is "gg", which goes to the line given by the count.
+func firstLineMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	return buffer.Position{Line: screen.file.buffer.LineAt(counted(count))}, linewise, true
+}
+
+// lastLineMotion is "G", which goes to the line given by the count, or
+// the last line if there is no count.
+func lastLineMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	if count == 0 {
+		count = screen.file.buffer.Lines
+	}
+	return buffer.Position{Line: screen.file.buffer.LineAt(count)}, linewise, true
+}
+
+func screenTopMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	lines := screen.visibleLines()
+	index := counted(count) - 1
+	if index >= len(lines) {
+		index = len(lines) - 1
+	}
+	return buffer.Position{Line: lines[index]}, linewise, true
+}
+
+func screenMiddleMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	lines := screen.visibleLines()
+	return buffer.Position{Line: lines[(len(lines)-1)/2]}, linewise, true
+}
+
+func screenBottomMotion(screen *Screen, count int, operating bool) (buffer.Position, motionKind, bool) {
+	lines := screen.visibleLines()
+	index := len(lines) - counted(count)
+	if index < 0 {
+		index = 0
+	}
+	return buffer.Position{Line: lines[index]}, linewise, true
+}
+
+func (screen *Screen) visibleLines() []*buffer.Line {
+	lines := make([]*buffer.Line, 0, screen.file.height)
+	for traverse := screen.firstLine; traverse != nil && len(lines) < screen.file.height; traverse = traverse.Next {
+		lines = append(lines, traverse)
+	}
+	return lines
+}
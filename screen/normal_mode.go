@@ -1,200 +1,441 @@
 package screen
 
 import (
+	"strconv"
+	"strings"
+	"unicode"
+
 	"github.com/bkthomps/Ven/buffer"
+	"github.com/bkthomps/Ven/keymap"
 	"github.com/gdamore/tcell/v2"
 )
 
-func (screen *Screen) executeNormalMode(ev *tcell.EventKey) {
-	previousCommand := screen.command.old
-	screen.command.old = ""
-	switch ev.Key() {
-	case tcell.KeyDown, tcell.KeyUp, tcell.KeyLeft, tcell.KeyRight:
-		screen.bufferAction(ev)
-	case tcell.KeyCtrlF:
-		screen.file.xCursor = screen.file.buffer.StartOfLine()
-		for i := 0; i < screen.file.height; i++ {
-			if screen.file.buffer.Current.Next == nil {
-				break
-			}
-			screen.firstLine = screen.firstLine.Next
-			screen.file.buffer.Current = screen.file.buffer.Current.Next
+// normalBinding is what a sequence of keys does in normal mode. It is
+// either a motion, an operator which waits for a motion or text object,
+// a text object, an action, or an alias which stands for other normal
+// mode keys. Bindings which are changes can be repeated by ".", and
+// motions which are jumps add the cursor to the jump list.
+type normalBinding struct {
+	keys     string
+	motion   motion
+	operator func(screen *Screen, r region)
+	object   object
+	action   func(screen *Screen, count int)
+	alias    string
+	argument bool
+	change   bool
+	jump     bool
+}
+
+type normalBindings map[string]*normalBinding
+
+var normalTable normalBindings
+
+func init() {
+	normalTable = newNormalBindings([]normalBinding{
+		{keys: "<Down>", motion: downMotion},
+		{keys: "j", motion: downMotion},
+		{keys: "<Up>", motion: upMotion},
+		{keys: "k", motion: upMotion},
+		{keys: "<Left>", motion: leftMotion},
+		{keys: "h", motion: leftMotion},
+		{keys: "<Right>", motion: rightMotion},
+		{keys: "l", motion: rightMotion},
+		{keys: "H", motion: screenTopMotion, jump: true},
+		{keys: "M", motion: screenMiddleMotion, jump: true},
+		{keys: "L", motion: screenBottomMotion, jump: true},
+		{keys: "0", motion: startOfLineMotion},
+		{keys: "$", motion: endOfLineMotion},
+		{keys: "gg", motion: firstLineMotion, jump: true},
+		{keys: "G", motion: lastLineMotion, jump: true},
+		{keys: "w", motion: nextWordStartMotion(words)},
+		{keys: "b", motion: prevWordStartMotion(words)},
+		{keys: "e", motion: nextWordEndMotion(words)},
+		{keys: "W", motion: nextWordStartMotion(bigWords)},
+		{keys: "B", motion: prevWordStartMotion(bigWords)},
+		{keys: "E", motion: nextWordEndMotion(bigWords)},
+		{keys: "f", motion: findMotion(true, false), argument: true},
+		{keys: "F", motion: findMotion(false, false), argument: true},
+		{keys: "t", motion: findMotion(true, true), argument: true},
+		{keys: "T", motion: findMotion(false, true), argument: true},
+		{keys: "}", motion: repeatedMotion((*buffer.File).NextParagraph), jump: true},
+		{keys: "{", motion: repeatedMotion((*buffer.File).PrevParagraph), jump: true},
+		{keys: ")", motion: repeatedMotion((*buffer.File).NextSentence), jump: true},
+		{keys: "(", motion: repeatedMotion((*buffer.File).PrevSentence), jump: true},
+		{keys: "%", motion: matchBracketMotion, jump: true},
+		{keys: "'", motion: markMotion(false), argument: true, jump: true},
+		{keys: "`", motion: markMotion(true), argument: true, jump: true},
+		{keys: ";", motion: repeatFindMotion(false)},
+		{keys: ",", motion: repeatFindMotion(true)},
+		{keys: "d", operator: (*Screen).deleteOperator, change: true},
+		{keys: "c", operator: (*Screen).changeOperator, change: true},
+		{keys: "y", operator: (*Screen).yankOperator},
+		{keys: "x", alias: "dl"},
+		{keys: "X", alias: "dh"},
+		{keys: "D", alias: "d$"},
+		{keys: "C", alias: "c$"},
+		{keys: "s", alias: "cl"},
+		{keys: "S", alias: "cc"},
+		{keys: "Y", alias: "yy"},
+		{keys: "r", action: (*Screen).replaceCharacters, argument: true, change: true},
+		{keys: "~", action: (*Screen).switchCase, change: true},
+		{keys: "gu", operator: transformOperator(unicode.ToLower), change: true},
+		{keys: "gU", operator: transformOperator(unicode.ToUpper), change: true},
+		{keys: "g~", operator: transformOperator(toggleCase), change: true},
+		{keys: ">", operator: shiftOperator(1), change: true},
+		{keys: "<lt>", operator: shiftOperator(-1), change: true},
+		{keys: "=", operator: (*Screen).reindentOperator, change: true},
+		{keys: "J", action: joinLines(false), change: true},
+		{keys: "gJ", action: joinLines(true), change: true},
+		{keys: "p", action: func(screen *Screen, count int) { screen.put(count, true) }, change: true},
+		{keys: "P", action: func(screen *Screen, count int) { screen.put(count, false) }, change: true},
+		{keys: "i", action: (*Screen).insertAtCursor, change: true},
+		{keys: "a", action: (*Screen).insertAfterCursor, change: true},
+		{keys: "A", action: (*Screen).insertAtEndOfLine, change: true},
+		{keys: "I", action: (*Screen).insertAtStartOfLine, change: true},
+		{keys: "o", action: (*Screen).openLineBelow, change: true},
+		{keys: "O", action: (*Screen).openLineAbove, change: true},
+		{keys: "R", action: (*Screen).startReplace, change: true},
+		{keys: ".", action: (*Screen).repeatChange},
+		{keys: "q", action: (*Screen).record, argument: true},
+		{keys: "@", action: (*Screen).playMacro, argument: true},
+		{keys: "m", action: (*Screen).setMark, argument: true},
+		{keys: "<C-o>", action: (*Screen).jumpOlder},
+		{keys: "<Tab>", action: (*Screen).jumpNewer},
+		{keys: "g;", action: stepChange(true)},
+		{keys: "g,", action: stepChange(false)},
+		{keys: ":", action: func(screen *Screen, count int) { screen.startCommand(':') }},
+		{keys: "/", action: func(screen *Screen, count int) { screen.startCommand('/') }},
+		{keys: "?", action: func(screen *Screen, count int) { screen.startCommand('?') }},
+		{keys: "<C-f>", action: (*Screen).pageForward},
+		{keys: "<C-b>", action: (*Screen).pageBackward},
+		{keys: "<C-d>", action: (*Screen).halfPageDown},
+		{keys: "<C-u>", action: (*Screen).halfPageUp},
+		{keys: "<C-e>", action: func(screen *Screen, count int) { screen.scrollLines(counted(count)) }},
+		{keys: "<C-y>", action: func(screen *Screen, count int) { screen.scrollLines(-counted(count)) }},
+		{keys: "zt", action: placeCursorLine(cursorAtTop, false)},
+		{keys: "z<CR>", action: placeCursorLine(cursorAtTop, true)},
+		{keys: "zz", action: placeCursorLine(cursorAtMiddle, false)},
+		{keys: "z.", action: placeCursorLine(cursorAtMiddle, true)},
+		{keys: "zb", action: placeCursorLine(cursorAtBottom, false)},
+		{keys: "z-", action: placeCursorLine(cursorAtBottom, true)},
+		{keys: "v", action: func(screen *Screen, count int) { screen.startVisual(false) }},
+		{keys: "V", action: func(screen *Screen, count int) { screen.startVisual(true) }},
+	})
+}
+
+func newNormalBindings(list []normalBinding) normalBindings {
+	table := make(normalBindings, len(list))
+	for i := range list {
+		table[keymap.Format(keymap.Parse(list[i].keys, nil))] = &list[i]
+	}
+	return table
+}
+
+func (table normalBindings) lookup(keys []keymap.Key) (bound *normalBinding, isPrefix bool) {
+	typed := keymap.Format(keys)
+	for keys := range table {
+		if len(keys) > len(typed) && strings.HasPrefix(keys, typed) {
+			isPrefix = true
+			break
+		}
+	}
+	return table[typed], isPrefix
+}
+
+// pendingCommand is a normal mode command which is still being typed,
+// in the form ["x][count][operator][count]motion[argument], where the
+// argument is a character which some commands such as "q" are followed by.
+type pendingCommand struct {
+	register         rune
+	awaitingRegister bool
+	count            int
+	operator         *normalBinding
+	operatorKeys     []keymap.Key
+	motionCount      int
+	sequence         []keymap.Key
+	awaiting         *normalBinding
+	argument         rune
+}
+
+func (screen *Screen) executeNormalMode(key keymap.Key) {
+	pending := &screen.pending
+	if pending.awaitingRegister {
+		pending.awaitingRegister = false
+		if r, ok := key.Character(); ok && isRegisterName(r) {
+			pending.register = r
+		} else {
+			screen.pending = pendingCommand{}
 		}
-		screen.completeDraw(nil)
-	case tcell.KeyCtrlB:
-		screen.file.xCursor = screen.file.buffer.StartOfLine()
-		for i := 0; i < screen.file.height; i++ {
-			if screen.firstLine.Prev == nil {
-				break
-			}
-			screen.firstLine = screen.firstLine.Prev
-			screen.file.buffer.Current = screen.file.buffer.Current.Prev
+		return
+	}
+	if pending.awaiting != nil {
+		bound := pending.awaiting
+		r, ok := key.Character()
+		if key.Code == tcell.KeyEnter {
+			r, ok = '\n', true
+		}
+		if !ok {
+			screen.pending = pendingCommand{}
+			return
+		}
+		pending.argument = r
+		pending.sequence = append(pending.sequence, key)
+		screen.runNormal(screen.takePending(), bound)
+		return
+	}
+	if screen.cmdwin != nil && screen.mode == normalMode && key.Code == tcell.KeyEnter && pending.operator == nil && len(pending.sequence) == 0 {
+		screen.runCommandWindowLine()
+		return
+	}
+	if len(pending.sequence) == 0 && key.Code == tcell.KeyRune && key.Mod == 0 {
+		if key.Rune == '"' && pending.count == 0 && pending.operator == nil {
+			pending.awaitingRegister = true
+			return
+		}
+		if pending.addDigit(key.Rune) {
+			return
 		}
+	}
+	pending.sequence = append(pending.sequence, key)
+	if pending.isRepeatedOperator() {
+		screen.runNormal(screen.takePending(), nil)
+		return
+	}
+	bound, isPrefix := screen.lookupNormal(pending.sequence)
+	if bound == nil && isPrefix {
+		return
+	}
+	if bound != nil && bound.operator != nil && pending.operator == nil && screen.mode != visualMode {
+		pending.operator = bound
+		pending.operatorKeys = pending.sequence
+		pending.sequence = nil
+		return
+	}
+	if bound != nil && screen.wantsArgument(bound) {
+		pending.awaiting = bound
+		return
+	}
+	command := screen.takePending()
+	if bound == nil {
+		screen.fail()
+		return
+	}
+	screen.runNormal(command, bound)
+}
+
+// wantsArgument reports whether the binding is followed by a character,
+// which "q" is not when it stops a recording.
+func (screen *Screen) wantsArgument(bound *normalBinding) bool {
+	return bound.argument && !(bound.keys == "q" && screen.input.recording != 0)
+}
+
+func (pending *pendingCommand) addDigit(r rune) bool {
+	count := &pending.count
+	if pending.operator != nil {
+		count = &pending.motionCount
+	}
+	if r < '0' || r > '9' || (r == '0' && *count == 0) {
+		return false
+	}
+	*count = *count*10 + int(r-'0')
+	return true
+}
+
+// isRepeatedOperator reports whether an operator was typed twice, such
+// as "dd", which makes it act on whole lines.
+func (pending *pendingCommand) isRepeatedOperator() bool {
+	if pending.operator == nil {
+		return false
+	}
+	operatorKeys := pending.operatorKeys
+	if keymap.Equal(pending.sequence, operatorKeys) {
+		return true
+	}
+	return len(pending.sequence) == 1 && pending.sequence[0] == operatorKeys[len(operatorKeys)-1]
+}
+
+// takePending returns the typed command and resets it, which must happen
+// before the command runs since "." and aliases type more keys.
+func (screen *Screen) takePending() pendingCommand {
+	command := screen.pending
+	screen.pending = pendingCommand{}
+	return command
+}
+
+func (screen *Screen) runNormal(command pendingCommand, bound *normalBinding) {
+	wasVisual := screen.mode == visualMode
+	screen.register = command.register
+	screen.argument = command.argument
+	count := command.count
+	if command.motionCount > 0 {
+		count = counted(count) * command.motionCount
+	}
+	switch {
+	case bound != nil && bound.alias != "":
+		screen.typeAlias(command, count, bound.alias)
+		return
+	case screen.mode == visualMode && bound.operator != nil:
+		screen.operateOnSelection(bound.operator)
+		screen.followCursor()
 		screen.completeDraw(nil)
-	default:
-		switch ev.Rune() {
-		case 'j':
-			screen.actionDown()
-		case 'k':
-			screen.actionUp()
-		case 'h':
-			screen.actionLeft()
-		case 'l':
-			screen.actionRight()
-		case 'i':
-			screen.mode = insertMode
-		case 'a':
-			screen.mode = insertMode
-			screen.actionRight()
-		case 'A':
-			screen.mode = insertMode
-			screen.file.xCursor = screen.file.buffer.EndOfLine(screen.mode == insertMode)
-		case 'I':
-			screen.mode = insertMode
-			screen.file.xCursor = screen.file.buffer.StartOfLine()
-		case 'o':
-			screen.file.xCursor = screen.file.buffer.EndOfLine(screen.mode == insertMode)
-			screen.actionKeyPress('\n')
-			screen.mode = insertMode
-		case 'O':
-			screen.file.xCursor = screen.file.buffer.StartOfLine()
-			screen.actionKeyPress('\n')
-			screen.actionUp()
-			screen.mode = insertMode
-		case ':', '/':
-			screen.mode = commandMode
-			screen.command.current = buffer.Line{Data: []rune{ev.Rune()}}
-			screen.command.runeOffset = 1
-			screen.command.spaceOffset = buffer.RuneWidthJump(ev.Rune(), 0)
-		case 'H':
-			screen.file.xCursor = screen.file.buffer.StartOfLine()
-			screen.navigateLineTop(0)
-		case 'M':
-			screen.file.xCursor = screen.file.buffer.StartOfLine()
-			height := screen.maxHeight()
-			screen.navigateLineTop(height / 2)
-			screen.navigateLineBottom(height / 2)
-		case 'L':
-			screen.file.xCursor = screen.file.buffer.StartOfLine()
-			height := screen.maxHeight()
-			screen.navigateLineBottom(height)
-		case '0':
-			screen.file.xCursor = screen.file.buffer.StartOfLine()
-		case '$':
-			screen.file.xCursor = screen.file.buffer.EndOfLine(screen.mode == insertMode)
-		case 'g':
-			if previousCommand != "g" {
-				screen.command.old = "g"
-				break
-			}
-			screen.file.xCursor = screen.file.buffer.JumpToTop()
-			screen.file.yCursor = 0
-			screen.firstLine = screen.file.buffer.Current
-			screen.completeDraw(nil)
-		case 'G':
-			screen.file.xCursor = screen.file.buffer.JumpToBottom()
-			screen.file.yCursor = screen.file.height - 1
-			screen.firstLine = screen.file.buffer.Current
-			for i := 0; i < screen.file.height-1; i++ {
-				if screen.firstLine.Prev == nil {
-					break
-				}
-				screen.firstLine = screen.firstLine.Prev
-			}
-			screen.completeDraw(nil)
-		case 'w':
-			x, linesDown := screen.file.buffer.NextWordStart()
-			screen.file.xCursor = x
-			for i := 0; i < linesDown; i++ {
-				if screen.file.yCursor == screen.file.height-1 {
-					screen.firstLine = screen.firstLine.Next
-				} else {
-					screen.file.yCursor++
-				}
-			}
-			if linesDown > 0 {
-				screen.completeDraw(nil)
-			}
-		case 'b':
-			x, linesUp := screen.file.buffer.PrevWordStart()
-			screen.file.xCursor = x
-			for i := 0; i < linesUp; i++ {
-				if screen.file.yCursor == 0 {
-					screen.firstLine = screen.firstLine.Prev
-				} else {
-					screen.file.yCursor--
-				}
-			}
-			if linesUp > 0 {
-				screen.completeDraw(nil)
-			}
-		case 'e':
-			x, linesDown := screen.file.buffer.NextWordEnd()
-			screen.file.xCursor = x
-			for i := 0; i < linesDown; i++ {
-				if screen.file.yCursor == screen.file.height-1 {
-					screen.firstLine = screen.firstLine.Next
-				} else {
-					screen.file.yCursor++
-				}
-			}
-			if linesDown > 0 {
-				screen.completeDraw(nil)
-			}
-		case 'x':
-			screen.file.xCursor = screen.file.buffer.Remove()
-			screen.drawLine(screen.file.yCursor, screen.file.buffer.Current.Data)
-		case 'X':
-			screen.file.xCursor = screen.file.buffer.RemoveBefore()
-			screen.drawLine(screen.file.yCursor, screen.file.buffer.Current.Data)
-		case 'd':
-			if previousCommand != "d" {
-				screen.command.old = "d"
-				break
-			}
-			x, wasFirst, wasLast := screen.file.buffer.RemoveLine(screen.mode == insertMode)
-			screen.file.xCursor = x
-			if wasFirst {
-				screen.firstLine = screen.firstLine.Next
-			} else if wasLast {
-				if screen.file.yCursor == 0 {
-					screen.firstLine = screen.firstLine.Prev
-				} else {
-					screen.file.yCursor--
-				}
-			}
+		return
+	case screen.mode == visualMode && bound.object != nil:
+		if !screen.selectObject(bound.object, count) {
+			screen.fail()
+		}
+	case command.operator != nil && bound == nil:
+		screen.operateOnLines(command.operator, count)
+	case command.operator != nil && bound.motion != nil:
+		if !screen.operateOnMotion(command, bound, count) {
+			screen.fail()
+			return
+		}
+	case command.operator != nil && bound.object != nil:
+		r, ok := bound.object(screen.file.buffer, counted(count))
+		if !ok {
+			screen.fail()
+			return
+		}
+		command.operator.operator(screen, screen.objectRegion(r))
+	case command.operator != nil:
+		screen.fail()
+		return
+	case bound.object != nil:
+		screen.fail()
+		return
+	case bound.motion != nil:
+		from := screen.here()
+		target, _, ok := bound.motion(screen, count, false)
+		if !ok {
+			target = screen.file.buffer.Cursor()
+			screen.fail()
+		}
+		screen.file.buffer.SetCursor(target, false)
+		if from.file != screen.file.buffer {
 			screen.completeDraw(nil)
-		case 'D':
-			screen.file.xCursor = screen.file.buffer.RemoveRestOfLine(screen.mode == insertMode)
-			screen.drawLine(screen.file.yCursor, screen.file.buffer.Current.Data)
 		}
+		if ok && bound.jump && screen.file.buffer.Compare(from.position, target) != 0 {
+			screen.recordJump(from)
+		}
+	default:
+		bound.action(screen, count)
+	}
+	isChange := bound != nil && bound.change
+	keys := command.sequence
+	if command.operator != nil {
+		isChange = command.operator.change
+		keys = append(append([]keymap.Key{}, command.operatorKeys...), command.sequence...)
+	}
+	if isChange {
+		screen.recordChange(&change{register: command.register, count: count, keys: keys})
+	}
+	screen.followCursor()
+	if isChange || wasVisual || screen.mode == visualMode {
+		screen.completeDraw(nil)
 	}
 }
 
-func (screen *Screen) navigateLineTop(lineIndex int) {
-	for screen.file.yCursor > lineIndex {
-		isPossible, _ := screen.file.buffer.Up(screen.mode == insertMode)
-		if !isPossible {
-			break
-		}
-		screen.file.yCursor--
+func (screen *Screen) typeAlias(command pendingCommand, count int, alias string) {
+	for _, key := range command.prefix(count) {
+		screen.executeNormalMode(key)
+	}
+	for _, key := range keymap.Parse(alias, nil) {
+		screen.executeNormalMode(key)
 	}
 }
 
-func (screen *Screen) navigateLineBottom(lineIndex int) {
-	for screen.file.yCursor < lineIndex {
-		isPossible, _ := screen.file.buffer.Down(screen.mode == insertMode)
-		if !isPossible {
-			break
+func (command pendingCommand) prefix(count int) []keymap.Key {
+	text := ""
+	if command.register != 0 {
+		text += `"` + string(command.register)
+	}
+	if count > 0 {
+		text += strconv.Itoa(count)
+	}
+	return keymap.Parse(text, nil)
+}
+
+func (screen *Screen) operateOnLines(operator *normalBinding, count int) {
+	first := screen.file.buffer.Current
+	last := first
+	for i := 1; i < counted(count) && last.Next != nil; i++ {
+		last = last.Next
+	}
+	operator.operator(screen, screen.linesRegion(first, last))
+}
+
+func (screen *Screen) operateOnMotion(command pendingCommand, bound *normalBinding, count int) bool {
+	file := screen.file.buffer
+	origin := file.Cursor()
+	operated := bound.motion
+	if keymap.Format(command.operatorKeys) == "c" {
+		switch keymap.Format(command.sequence) {
+		case "w":
+			operated = changeWordMotion(words)
+		case "W":
+			operated = changeWordMotion(bigWords)
 		}
-		screen.file.yCursor++
 	}
+	target, kind, ok := operated(screen, count, true)
+	file.SetCursor(origin, false)
+	if !ok {
+		return false
+	}
+	command.operator.operator(screen, screen.motionRegion(origin, target, kind))
+	return true
+}
+
+func (screen *Screen) recordChange(made *change) {
+	if screen.inserting() && screen.insertion != nil {
+		screen.insertion.change = made
+		return
+	}
+	screen.lastChange = made
+}
+
+func (screen *Screen) insertAtCursor(count int) {
+	screen.startInsert(count, false)
+}
+
+func (screen *Screen) insertAfterCursor(count int) {
+	screen.startInsert(count, false)
+	screen.actionRight()
+}
+
+func (screen *Screen) insertAtEndOfLine(count int) {
+	screen.startInsert(count, false)
+	screen.file.xCursor = screen.file.buffer.EndOfLine(true)
+}
+
+func (screen *Screen) insertAtStartOfLine(count int) {
+	screen.startInsert(count, false)
+	screen.file.xCursor = screen.file.buffer.StartOfLine()
+}
+
+func (screen *Screen) openLineBelow(count int) {
+	screen.openLine(false)
+	screen.startInsert(count, true)
+}
+
+func (screen *Screen) openLineAbove(count int) {
+	screen.openLine(true)
+	screen.startInsert(count, true)
+}
+
+func (screen *Screen) openLine(above bool) {
+	screen.file.xCursor = screen.file.buffer.OpenLine(above)
+	screen.followCursor()
+	screen.completeDraw(nil)
 }
 
-func (screen *Screen) maxHeight() int {
-	height := screen.file.height - 1
-	if screen.file.buffer.Lines < height {
-		height = screen.file.buffer.Lines
+func (screen *Screen) startCommand(r rune) {
+	screen.mode = commandMode
+	screen.command.current = buffer.Line{Data: []rune{r}}
+	screen.command.recall = nil
+	screen.command.awaitingRegister = false
+	screen.commandMoveTo(1)
+	if r == '/' || r == '?' {
+		screen.startSearch()
 	}
-	return height
 }
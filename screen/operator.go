@@ -0,0 +1,190 @@
+package screen
+
+import "github.com/bkthomps/Ven/buffer"
+
+// region is the text which an operator acts on. The end is exclusive,
+// and a linewise region covers every line from start to end.
+type region struct {
+	start    buffer.Position
+	end      buffer.Position
+	linewise bool
+}
+
+func (screen *Screen) motionRegion(origin, target buffer.Position, kind motionKind) region {
+	start, end := origin, target
+	if screen.file.buffer.Compare(start, end) > 0 {
+		start, end = end, start
+	}
+	switch kind {
+	case linewise:
+		return screen.linesRegion(start.Line, end.Line)
+	case inclusive:
+		end.Offset++
+		return region{start: start, end: end}
+	}
+	if end.Offset == 0 && end.Line != start.Line {
+		end = buffer.Position{Line: end.Line.Prev, Offset: len(end.Line.Prev.Data)}
+		if start.Offset <= start.Line.FirstNonBlank() {
+			return screen.linesRegion(start.Line, end.Line)
+		}
+	}
+	return region{start: start, end: end}
+}
+
+func (screen *Screen) linesRegion(first, last *buffer.Line) region {
+	return region{
+		start:    buffer.Position{Line: first},
+		end:      buffer.Position{Line: last, Offset: len(last.Data)},
+		linewise: true,
+	}
+}
+
+func (screen *Screen) objectRegion(r buffer.Range) region {
+	if r.Linewise {
+		return screen.linesRegion(r.Start.Line, r.End.Line)
+	}
+	return region{start: r.Start, end: r.End}
+}
+
+func (screen *Screen) regionText(r region) []rune {
+	if r.linewise {
+		return screen.file.buffer.LinesText(r.start.Line, r.end.Line)
+	}
+	return screen.file.buffer.Text(r.start, r.end)
+}
+
+func (screen *Screen) deleteOperator(r region) {
+	file := screen.file.buffer
+	screen.registers.store(screen.register, screen.regionText(r), r.linewise, false)
+	if r.linewise {
+		screen.releaseLines(r.start.Line, r.end.Line)
+		file.DeleteLines(r.start.Line, r.end.Line)
+		return
+	}
+	if r.start.Line != r.end.Line {
+		screen.releaseLines(r.start.Line.Next, r.end.Line)
+	}
+	file.Delete(r.start, r.end, false)
+}
+
+func (screen *Screen) changeOperator(r region) {
+	file := screen.file.buffer
+	screen.registers.store(screen.register, screen.regionText(r), r.linewise, false)
+	if r.start.Line != r.end.Line {
+		screen.releaseLines(r.start.Line.Next, r.end.Line)
+	}
+	if r.linewise {
+		if r.start.Line != r.end.Line {
+			file.DeleteLines(r.start.Line.Next, r.end.Line)
+		}
+		r.end = buffer.Position{Line: r.start.Line, Offset: len(r.start.Line.Data)}
+	}
+	file.Delete(r.start, r.end, true)
+	screen.startInsert(1, false)
+}
+
+func (screen *Screen) yankOperator(r region) {
+	screen.registers.store(screen.register, screen.regionText(r), r.linewise, true)
+	if r.linewise && screen.file.buffer.Current == r.start.Line {
+		return
+	}
+	screen.file.buffer.SetCursor(r.start, false)
+}
+
+// shiftOperator is ">" or "<", which indent or remove indentation from the
+// lines of the region by a number of levels.
+func shiftOperator(levels int) func(screen *Screen, r region) {
+	return func(screen *Screen, r region) {
+		screen.file.buffer.ShiftLines(r.start.Line, r.end.Line, levels)
+	}
+}
+
+// reindentOperator is "=", which indents the lines of the region by the
+// brackets around them.
+func (screen *Screen) reindentOperator(r region) {
+	screen.file.buffer.ReindentLines(r.start.Line, r.end.Line)
+}
+
+// joinOperator joins the lines of the region, or the first line with the
+// line after it when the region is only one line.
+func joinOperator(keepWhitespace bool) func(screen *Screen, r region) {
+	return func(screen *Screen, r region) {
+		last := r.end.Line
+		if last == r.start.Line {
+			last = last.Next
+		}
+		if last == nil {
+			screen.fail()
+			return
+		}
+		count := 1
+		for line := r.start.Line; line != last; line = line.Next {
+			count++
+		}
+		screen.releaseLines(r.start.Line.Next, last)
+		screen.file.buffer.JoinLines(r.start.Line, count, keepWhitespace)
+	}
+}
+
+// joinLines is "J", or "gJ" when the whitespace is kept, which joins the
+// count lines, and at least two, starting with the cursor line.
+func joinLines(keepWhitespace bool) func(screen *Screen, count int) {
+	return func(screen *Screen, count int) {
+		file := screen.file.buffer
+		last := file.Current
+		for i := 1; i < count && last.Next != nil; i++ {
+			last = last.Next
+		}
+		joinOperator(keepWhitespace)(screen, screen.linesRegion(file.Current, last))
+	}
+}
+
+// releaseLines moves the top of the screen off of lines which are about
+// to be deleted.
+func (screen *Screen) releaseLines(first, last *buffer.Line) {
+	for line := first; line != nil; line = line.Next {
+		if line == screen.firstLine {
+			if last.Next != nil {
+				screen.firstLine = last.Next
+			} else if first.Prev != nil {
+				screen.firstLine = first.Prev
+			}
+			return
+		}
+		if line == last {
+			return
+		}
+	}
+}
+
+func (screen *Screen) put(count int, after bool) {
+	stored, err := screen.registers.load(screen.register)
+	if err != nil {
+		screen.message = errorText(err)
+		screen.fail()
+		return
+	}
+	text := make([]rune, 0, len(stored.text)*counted(count))
+	for i := 0; i < counted(count); i++ {
+		text = append(text, stored.text...)
+	}
+	file := screen.file.buffer
+	if stored.linewise {
+		file.InsertLines(text, after)
+		return
+	}
+	cursor := file.Cursor()
+	if after && len(cursor.Line.Data) > 0 {
+		cursor.Offset++
+		file.SetCursor(cursor, true)
+	}
+	start := file.Cursor()
+	file.InsertText(text)
+	if containsNewline(text) {
+		file.SetCursor(start, false)
+		return
+	}
+	end := file.Cursor()
+	end.Offset--
+	file.SetCursor(end, false)
+}
@@ -0,0 +1,355 @@
+package screen
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/bkthomps/Ven/buffer"
+	"github.com/bkthomps/Ven/errorformat"
+	"github.com/bkthomps/Ven/search"
+	"github.com/gdamore/tcell/v2"
+)
+
+// The kinds of the command-line window which hold the quickfix list and
+// the location list. Commands which fill or move through a list take its
+// kind too.
+const (
+	quickfixKind = 'q'
+	locationKind = 'l'
+)
+
+var (
+	noErrors      = errors.New("No Errors")
+	noMoreItems   = errors.New("No More Items")
+	makeFailed    = errors.New("Make Failed")
+	makeRunning   = errors.New("Make Is Already Running")
+	quickfixTitle = []rune("[Quickfix List]")
+	locationTitle = []rune("[Location List]")
+)
+
+// quickfixEntry is a place in a file, with the line and column counting
+// from 1, and the text to show for it. The kind is the type of an error
+// from :make, such as 'E' or 'W', or 0 when there is none.
+type quickfixEntry struct {
+	fileName string
+	line     int
+	column   int
+	text     string
+	kind     rune
+}
+
+// quickfixList is the list of places found by :grep or :make, and the
+// index of the entry which was last gone to. The quickfix list belongs to
+// the screen, while each window has its own location list. The lines of
+// the errors from :make are marked in the gutter, so signs holds the kind
+// of the entry on each line of each file, by the absolute path of the file.
+type quickfixList struct {
+	entries []quickfixEntry
+	index   int
+	signs   map[string]map[int]rune
+}
+
+// list is the quickfix list, or the location list of the window with the
+// file in it, even while the command-line window is open.
+func (screen *Screen) list(kind rune) *quickfixList {
+	if kind == locationKind {
+		if screen.cmdwin != nil {
+			return &screen.cmdwin.other.locations
+		}
+		return &screen.file.locations
+	}
+	return &screen.quickfix
+}
+
+// fillList replaces the entries of a list, and goes to the first one
+// unless there is a bang. Entries which are marked get a sign in the
+// gutter.
+func (screen *Screen) fillList(kind rune, entries []quickfixEntry, bang, marked bool) error {
+	list := screen.list(kind)
+	*list = quickfixList{entries: entries}
+	if marked {
+		list.signs = make(map[string]map[int]rune)
+		for _, entry := range entries {
+			name, err := filepath.Abs(entry.fileName)
+			if err != nil {
+				continue
+			}
+			if list.signs[name] == nil {
+				list.signs[name] = make(map[int]rune)
+			}
+			if existing, ok := list.signs[name][entry.line]; !ok || existing != 'E' {
+				list.signs[name][entry.line] = entry.kind
+			}
+		}
+	}
+	if bang {
+		screen.message = []rune(fmt.Sprintf("(1 of %d)", len(entries)))
+		screen.completeDraw(nil)
+		return nil
+	}
+	return screen.listJump(kind, 0)
+}
+
+// grepCommand searches the files under the working directory, or under
+// the directory after the pattern, and fills a list with the lines which
+// match. The pattern may be put between two of a character which is not a
+// letter, such as "/a b/", to have spaces in it, and an empty pattern uses
+// the last search. It is both :grep and :vimgrep.
+func grepCommand(kind rune) func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+	return func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+		if screen.cmdwin != nil {
+			return invalidInCommandWindow
+		}
+		text, directory := splitGrepArguments(arguments)
+		if text == "" {
+			searches := screen.histories['/']
+			if len(searches) == 0 {
+				return noPreviousPattern
+			}
+			text = searches[len(searches)-1]
+		}
+		if directory == "" {
+			directory = "."
+		}
+		pattern, err := screen.searchPattern(text)
+		if err != nil {
+			return err
+		}
+		hits, err := search.Grep(directory, pattern)
+		if err != nil {
+			return fmt.Errorf("Cannot Search %s", directory)
+		}
+		if len(hits) == 0 {
+			return patternNotFound
+		}
+		entries := make([]quickfixEntry, 0, len(hits))
+		for _, hit := range hits {
+			entries = append(entries, quickfixEntry{fileName: hit.Path, line: hit.Line, column: hit.Column, text: hit.Text})
+		}
+		return screen.fillList(kind, entries, bang, false)
+	}
+}
+
+func splitGrepArguments(arguments string) (pattern, directory string) {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" {
+		return "", ""
+	}
+	delimiter := []rune(arguments)[0]
+	if !unicode.IsLetter(delimiter) && !unicode.IsDigit(delimiter) && delimiter != '\\' && delimiter != '"' {
+		rest := arguments[len(string(delimiter)):]
+		if end := strings.IndexRune(rest, delimiter); end >= 0 {
+			return rest[:end], strings.TrimSpace(rest[end+len(string(delimiter)):])
+		}
+		return rest, ""
+	}
+	fields := strings.SplitN(arguments, " ", 2)
+	if len(fields) == 1 {
+		return fields[0], ""
+	}
+	return fields[0], strings.TrimSpace(fields[1])
+}
+
+// makeEvent is posted once the command of :make has finished, with what
+// it wrote and how it exited.
+type makeEvent struct {
+	tcell.EventTime
+	kind    rune
+	bang    bool
+	command string
+	formats errorformat.Formats
+	output  string
+	err     error
+}
+
+// makeCommand runs makeprg in the shell, with any arguments after it, and
+// fills a list with the errors which errorformat reads from its output.
+// The command runs in the background, so that the editor can still be
+// used while it does.
+func makeCommand(kind rune) func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+	return func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+		if screen.cmdwin != nil {
+			return invalidInCommandWindow
+		}
+		if screen.making {
+			return makeRunning
+		}
+		formats, err := errorformat.Compile(screen.options.String("errorformat"))
+		if err != nil {
+			return err
+		}
+		command := strings.TrimSpace(screen.options.String("makeprg") + " " + arguments)
+		screen.making = true
+		screen.message = []rune("Running " + command + "...")
+		go func() {
+			output, err := exec.Command("sh", "-c", command).CombinedOutput()
+			ev := &makeEvent{kind: kind, bang: bang, command: command, formats: formats, output: string(output), err: err}
+			ev.SetEventNow()
+			screen.tCell.PostEventWait(ev)
+		}()
+		return nil
+	}
+}
+
+// finishMake fills a list with the errors of a :make which has finished.
+// It only goes to the first error when nothing else, such as insert mode
+// or the command-line window, was started while the command ran.
+func (screen *Screen) finishMake(ev *makeEvent) error {
+	screen.making = false
+	var exitError *exec.ExitError
+	if ev.err != nil && !errors.As(ev.err, &exitError) {
+		return fmt.Errorf("Cannot Run %s", ev.command)
+	}
+	entries := make([]quickfixEntry, 0)
+	for _, entry := range ev.formats.Parse(ev.output) {
+		column := entry.Column
+		if column == 0 {
+			column = 1
+		}
+		entries = append(entries, quickfixEntry{fileName: entry.File, line: entry.Line, column: column, text: entry.Message, kind: entry.Type})
+	}
+	if len(entries) > 0 {
+		bang := ev.bang || screen.mode != normalMode || screen.cmdwin != nil
+		return screen.fillList(ev.kind, entries, bang, true)
+	}
+	*screen.list(ev.kind) = quickfixList{}
+	screen.completeDraw(nil)
+	if ev.err != nil {
+		if line := firstLine(ev.output); line != "" {
+			return fmt.Errorf("%w: %s", makeFailed, line)
+		}
+		return makeFailed
+	}
+	screen.message = []rune("No Errors")
+	return nil
+}
+
+func firstLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// listMoveCommand goes to the next or previous entry of a list.
+func listMoveCommand(kind rune, step int) func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+	return func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+		if screen.cmdwin != nil {
+			return invalidInCommandWindow
+		}
+		list := screen.list(kind)
+		if len(list.entries) == 0 {
+			return noErrors
+		}
+		index := list.index + step
+		if index < 0 || index >= len(list.entries) {
+			return noMoreItems
+		}
+		return screen.listJump(kind, index)
+	}
+}
+
+// listJump goes to an entry of a list, loading its file if it is not the
+// one on the screen.
+func (screen *Screen) listJump(kind rune, index int) error {
+	list := screen.list(kind)
+	if index < 0 || index >= len(list.entries) {
+		return noErrors
+	}
+	entry := list.entries[index]
+	from := screen.here()
+	if !sameFile(entry.fileName, screen.file.buffer.Name) {
+		if !screen.file.buffer.CanSafeQuit() {
+			return modifiedFile
+		}
+		screen.loadBuffer(entry.fileName)
+	}
+	list.index = index
+	file := screen.file.buffer
+	file.SetCursor(buffer.Position{Line: file.LineAt(entry.line), Offset: entry.column - 1}, false)
+	screen.recordJump(from)
+	screen.followCursor()
+	screen.completeDraw(nil)
+	screen.message = []rune(fmt.Sprintf("(%d of %d): %s", index+1, len(list.entries), entry.text))
+	return nil
+}
+
+// listOpenCommand opens the window of a list, with the cursor on the entry
+// which was last gone to.
+func listOpenCommand(kind rune) func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+	return func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+		if screen.cmdwin != nil {
+			return invalidInCommandWindow
+		}
+		list := screen.list(kind)
+		listed := make([]string, 0, len(list.entries))
+		for _, entry := range list.entries {
+			listed = append(listed, entry.listed())
+		}
+		screen.openWindow(kind, listed, list.index+1, 0)
+		return nil
+	}
+}
+
+func (entry quickfixEntry) listed() string {
+	text := fmt.Sprintf("%s|%d col %d", entry.fileName, entry.line, entry.column)
+	switch entry.kind {
+	case 0:
+	case 'E':
+		text += " error"
+	case 'W':
+		text += " warning"
+	default:
+		text += " " + string(entry.kind)
+	}
+	return text + "| " + entry.text
+}
+
+// listCloseCommand closes the window of a list if it is open.
+func listCloseCommand(kind rune) func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+	return func(screen *Screen, lines lineRange, bang bool, arguments string) error {
+		if screen.cmdwin != nil && screen.cmdwin.kind == kind {
+			screen.closeCommandWindow()
+		}
+		return nil
+	}
+}
+
+// fileSigns are the signs of the quickfix list and the location list in
+// the file on the screen.
+func (screen *Screen) fileSigns() []map[int]rune {
+	if screen.file.buffer == nil || screen.file.buffer.Name == "" {
+		return nil
+	}
+	name, err := filepath.Abs(screen.file.buffer.Name)
+	if err != nil {
+		return nil
+	}
+	signs := make([]map[int]rune, 0)
+	for _, list := range []*quickfixList{&screen.quickfix, &screen.file.locations} {
+		if lines := list.signs[name]; lines != nil {
+			signs = append(signs, lines)
+		}
+	}
+	return signs
+}
+
+// sign is the text in the gutter of a line with an error from :make, such
+// as "E>", where an error without a kind is shown as one.
+func sign(signs []map[int]rune, lineNumber int) ([]rune, bool) {
+	for _, lines := range signs {
+		if kind, ok := lines[lineNumber]; ok {
+			if kind == 0 {
+				kind = 'E'
+			}
+			return []rune{kind, '>'}, true
+		}
+	}
+	return nil, false
+}
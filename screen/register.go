@@ -0,0 +1,87 @@
+package screen
+
+import (
+	"errors"
+	"unicode"
+)
+
+var emptyRegister = errors.New("Nothing In Register")
+
+const unnamedRegister = '"'
+
+type register struct {
+	text     []rune
+	linewise bool
+}
+
+// registers holds yanked and deleted text. As in vim, "" is the unnamed
+// register, "0 holds the last yank, "1 to "9 hold the last deletes of
+// a line or more, "- holds the last smaller delete, "a to "z are named
+// registers which "A to "Z append to, and "_ discards text.
+type registers map[rune]register
+
+func isRegisterName(r rune) bool {
+	return r == unnamedRegister || r == '-' || r == '_' ||
+		(r >= '0' && r <= '9') || (unicode.IsLetter(r) && r < unicode.MaxASCII)
+}
+
+func (all registers) store(name rune, text []rune, linewise bool, isYank bool) {
+	if name == '_' {
+		return
+	}
+	stored := register{text: append([]rune{}, text...), linewise: linewise}
+	switch {
+	case name >= 'A' && name <= 'Z':
+		name = unicode.ToLower(name)
+		existing, ok := all[name]
+		if ok {
+			stored.text = append(existing.text, stored.text...)
+			stored.linewise = existing.linewise || linewise
+		}
+		all[name] = stored
+	case name != 0 && name != unnamedRegister:
+		all[name] = stored
+	case isYank:
+		all['0'] = stored
+	case linewise || containsNewline(text):
+		for i := '9'; i > '1'; i-- {
+			if shifted, ok := all[i-1]; ok {
+				all[i] = shifted
+			}
+		}
+		all['1'] = stored
+	default:
+		all['-'] = stored
+	}
+	all[unnamedRegister] = stored
+}
+
+// record puts a macro into a register. Unlike yanks and deletes, this
+// leaves the unnamed register as it is.
+func (all registers) record(name rune, text []rune) {
+	if name >= 'A' && name <= 'Z' {
+		name = unicode.ToLower(name)
+		text = append(append([]rune{}, all[name].text...), text...)
+	}
+	all[name] = register{text: text}
+}
+
+func (all registers) load(name rune) (register, error) {
+	if name == 0 {
+		name = unnamedRegister
+	}
+	stored, ok := all[unicode.ToLower(name)]
+	if !ok || len(stored.text) == 0 {
+		return register{}, emptyRegister
+	}
+	return stored, nil
+}
+
+func containsNewline(text []rune) bool {
+	for _, r := range text {
+		if r == '\n' {
+			return true
+		}
+	}
+	return false
+}
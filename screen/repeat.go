@@ -0,0 +1,72 @@
+package screen
+
+import "github.com/bkthomps/Ven/keymap"
+
+// change is the last command which modified the file, kept so that it
+// can be repeated by ".". The keys do not include the count or register,
+// and inserted holds what was typed if the command went into insert mode.
+type change struct {
+	register rune
+	count    int
+	keys     []keymap.Key
+	inserted []keymap.Key
+}
+
+// insertion records what is typed during insert mode, so that a count
+// can insert it several times and "." can type it again.
+type insertion struct {
+	count  int
+	open   bool
+	typed  []keymap.Key
+	change *change
+}
+
+func (screen *Screen) startInsert(count int, open bool) {
+	screen.mode = insertMode
+	screen.insertion = &insertion{count: count, open: open}
+}
+
+// finishInsert types the inserted text again for the rest of the count,
+// and records the change which started insert mode.
+func (screen *Screen) finishInsert() {
+	current := screen.insertion
+	if current == nil {
+		return
+	}
+	screen.insertion = nil
+	for i := 1; i < current.count; i++ {
+		if current.open {
+			screen.actionKeyPress('\n')
+		}
+		for _, key := range current.typed {
+			screen.executeInsertMode(key)
+		}
+	}
+	if current.change != nil {
+		current.change.inserted = current.typed
+		screen.lastChange = current.change
+	}
+}
+
+func (screen *Screen) repeatChange(count int) {
+	last := screen.lastChange
+	if last == nil {
+		return
+	}
+	if count == 0 {
+		count = last.count
+	}
+	keys := pendingCommand{register: last.register}.prefix(count)
+	keys = append(keys, last.keys...)
+	inserted := last.inserted
+	for _, key := range keys {
+		screen.executeNormalMode(key)
+	}
+	if !screen.inserting() {
+		return
+	}
+	for _, key := range inserted {
+		screen.executeInsertMode(key)
+	}
+	screen.exitInsertMode()
+}
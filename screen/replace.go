@@ -0,0 +1,140 @@
+package screen
+
+import (
+	"unicode"
+
+	"github.com/bkthomps/Ven/buffer"
+)
+
+// replacement is a rune which was typed in replace mode, along with the
+// rune it overwrote so that backspace can restore it. Runes which were
+// typed past the end of the line, and line breaks, were added instead.
+type replacement struct {
+	original rune
+	added    bool
+	after    buffer.Position
+}
+
+func (screen *Screen) inserting() bool {
+	return screen.mode == insertMode || screen.mode == replaceMode
+}
+
+// startReplace is "R", which goes into replace mode, where typed runes
+// overwrite the runes under the cursor.
+func (screen *Screen) startReplace(count int) {
+	screen.startInsert(count, false)
+	screen.mode = replaceMode
+	screen.replaced = nil
+}
+
+func (screen *Screen) replaceKeyPress(r rune) {
+	file := screen.file.buffer
+	typed := replacement{added: true}
+	if r == '\n' || file.Cursor().Offset >= len(file.Current.Data) {
+		x, addedLine := file.Add(r)
+		screen.file.xCursor = x
+		if addedLine {
+			screen.followCursor()
+			screen.completeDraw(nil)
+		}
+	} else {
+		typed = replacement{original: file.ReplaceRune(r)}
+		screen.file.xCursor = file.Right(true)
+	}
+	typed.after = file.Cursor()
+	screen.replaced = append(screen.replaced, typed)
+}
+
+// replaceBackspace undoes the last rune typed in replace mode. Once the
+// cursor has moved elsewhere, it only moves the cursor left.
+func (screen *Screen) replaceBackspace() {
+	file := screen.file.buffer
+	last := len(screen.replaced) - 1
+	if last < 0 || file.Compare(screen.replaced[last].after, file.Cursor()) != 0 {
+		screen.replaced = nil
+		screen.actionLeft()
+		return
+	}
+	typed := screen.replaced[last]
+	screen.replaced = screen.replaced[:last]
+	if typed.added {
+		x, deletedLine := file.Backspace()
+		screen.file.xCursor = x
+		if deletedLine {
+			screen.followCursor()
+			screen.completeDraw(nil)
+		}
+		return
+	}
+	screen.file.xCursor = file.Left()
+	file.ReplaceRune(typed.original)
+}
+
+// replaceCharacters is "r", which replaces the rune under the cursor, and
+// with a count the runes after it, with the argument. As "r<CR>", the
+// runes are replaced by a single line break.
+func (screen *Screen) replaceCharacters(count int) {
+	file := screen.file.buffer
+	start := file.Cursor()
+	end := buffer.Position{Line: start.Line, Offset: start.Offset + counted(count)}
+	if end.Offset > len(start.Line.Data) {
+		screen.fail()
+		return
+	}
+	if screen.argument == '\n' {
+		file.Delete(start, end, true)
+		file.Add('\n')
+		screen.file.xCursor = file.XPosition()
+		return
+	}
+	argument := screen.argument
+	file.Transform(start, end, func(rune) rune { return argument })
+	screen.file.xCursor = file.SetCursor(buffer.Position{Line: start.Line, Offset: end.Offset - 1}, false)
+}
+
+// replaceSelection is "r" in visual mode, which replaces every rune of the
+// selection with the argument.
+func (screen *Screen) replaceSelection(count int) {
+	argument := screen.argument
+	if argument == '\n' {
+		screen.exitVisual()
+		screen.fail()
+		return
+	}
+	screen.operateOnSelection(transformOperator(func(rune) rune { return argument }))
+}
+
+// switchCase is "~", which switches the case of the rune under the cursor,
+// and with a count the runes after it, and moves the cursor past them.
+func (screen *Screen) switchCase(count int) {
+	file := screen.file.buffer
+	start := file.Cursor()
+	if len(start.Line.Data) == 0 {
+		screen.fail()
+		return
+	}
+	end := buffer.Position{Line: start.Line, Offset: start.Offset + counted(count)}
+	file.Transform(start, end, toggleCase)
+	screen.file.xCursor = file.SetCursor(end, false)
+}
+
+// transformOperator replaces each rune in the region with what the
+// transform returns for it, which is how "gu", "gU" and "g~" change case,
+// and then moves the cursor to the start of the region.
+func transformOperator(transform func(rune) rune) func(screen *Screen, r region) {
+	return func(screen *Screen, r region) {
+		file := screen.file.buffer
+		file.Transform(r.start, r.end, transform)
+		if r.linewise && file.Current == r.start.Line {
+			return
+		}
+		file.SetCursor(r.start, false)
+	}
+}
+
+func toggleCase(r rune) rune {
+	if unicode.IsUpper(r) {
+		return unicode.ToLower(r)
+	}
+	return unicode.ToUpper(r)
+}
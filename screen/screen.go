@@ -1,56 +1,101 @@
 package screen
 
 import (
+	"errors"
 	"log"
 
 	"github.com/bkthomps/Ven/buffer"
+	"github.com/bkthomps/Ven/keymap"
+	"github.com/bkthomps/Ven/option"
 	"github.com/bkthomps/Ven/search"
 	"github.com/gdamore/tcell/v2"
 )
 
 const (
 	insertMode = iota
+	replaceMode
 	normalMode
 	commandMode
 	commandErrorMode
 	highlightMode
+	visualMode
 )
 
 var (
-	insertMessage = []rune("-- INSERT --")
-	errorCommand  = []rune("-- Invalid Command --")
-	errorSave     = []rune("-- Could Not Save File --")
-	modifiedFile  = []rune("-- File Has Been Modified Since Last Save --")
-	badRegex      = []rune("-- Malformed Regex --")
-	noFilename    = []rune("-- No File Name Specified --")
-	tooManyFiles  = []rune("-- Must Specify A Single File --")
+	insertMessage  = []rune("-- INSERT --")
+	replaceMessage = []rune("-- REPLACE --")
+)
+
+var (
+	errorCommand = errors.New("Invalid Command")
+	errorSave    = errors.New("Could Not Save File")
+	modifiedFile = errors.New("File Has Been Modified Since Last Save")
+	badRegex     = errors.New("Malformed Regex")
+	noFilename   = errors.New("No File Name Specified")
+	tooManyFiles = errors.New("Must Specify A Single File")
 )
 
 var (
 	terminalStyle  = tcell.StyleDefault.Foreground(tcell.ColorBlack)
 	highlightStyle = terminalStyle.Background(tcell.ColorYellow)
+	gutterStyle    = terminalStyle.Foreground(tcell.ColorOlive)
+	visualStyle    = terminalStyle.Background(tcell.ColorSilver)
+	menuStyle      = terminalStyle.Background(tcell.ColorSilver)
+	signStyle      = terminalStyle.Foreground(tcell.ColorRed)
+)
+
+const (
+	minimumNumberWidth = 3
+	signWidth          = 2
 )
 
 type Screen struct {
 	tCell     tcell.Screen
+	quit      chan struct{}
 	mode      int
 	firstLine *buffer.Line
+	message   []rune
+	options   *option.Options
 
 	height int
 	width  int
 
-	file    *file
-	command *command
+	file     *file
+	command  *command
+	input    *input
+	mappings map[int]*keymap.Map
+
+	pending    pendingCommand
+	register   rune
+	argument   rune
+	registers  registers
+	lastChange *change
+	lastFind   *characterFind
+	insertion  *insertion
+	replaced   []replacement
+	visual     visual
+
+	globalMarks map[rune]*location
+	jumps       jumpList
+	histories   map[rune]history
+	cmdwin      *commandWindow
+	quickfix    quickfixList
+	making      bool
+
+	searchOrigin *searchOrigin
+	noHighlight  bool
 }
 
 type file struct {
 	xCursor int
 	yCursor int
 
+	top    int
 	height int
 	width  int
 
-	buffer *buffer.File
+	buffer    *buffer.File
+	locations quickfixList
 }
 
 type command struct {
@@ -58,18 +103,32 @@ type command struct {
 	spaceOffset int
 	yPosition   int
 	current     buffer.Line
-	old         string
+
+	recall           *recall
+	completion       *completion
+	awaitingRegister bool
 }
 
 func (screen *Screen) Init(tCellScreen tcell.Screen, quit chan struct{}, fileName string) {
 	screen.tCell = tCellScreen
+	screen.quit = quit
 	screen.mode = normalMode
 	screen.command = &command{}
-	buf := &buffer.File{}
-	buf.Init(fileName)
-	screen.firstLine = buf.First
+	screen.input = &input{}
+	screen.registers = registers{}
+	screen.globalMarks = make(map[rune]*location)
+	screen.mappings = map[int]*keymap.Map{
+		normalMode:  {},
+		insertMode:  {},
+		commandMode: {},
+		visualMode:  {},
+	}
+	screen.options = &option.Options{}
+	screen.options.Init(nil)
 	screen.file = &file{}
-	screen.file.buffer = buf
+	screen.loadConfig()
+	screen.loadHistory()
+	screen.loadBuffer(fileName)
 	if err := screen.tCell.Init(); err != nil {
 		log.Fatal(err)
 	}
@@ -78,36 +137,49 @@ func (screen *Screen) Init(tCellScreen tcell.Screen, quit chan struct{}, fileNam
 	screen.updateProperties()
 	screen.completeDraw(nil)
 	screen.displayMode()
-	go screen.listener(quit)
+	go screen.listener()
 }
 
 func (screen *Screen) updateProperties() {
 	x, y := screen.tCell.Size()
 	screen.height = y
 	screen.width = x
+	screen.command.yPosition = y - 1
+	if screen.cmdwin != nil {
+		screen.layoutCommandWindow(y-1, x)
+		return
+	}
+	screen.file.top = 0
 	screen.file.height = y - 1
 	screen.file.width = x
-	screen.command.yPosition = y - 1
 }
 
 func (screen *Screen) completeDraw(matchLines []search.MatchLine) {
+	if screen.cmdwin != nil && !screen.cmdwin.swapped {
+		screen.drawOtherWindow()
+	}
+	screen.drawWindow(matchLines)
+}
+
+// drawWindow draws the lines of the file which are on the screen.
+func (screen *Screen) drawWindow(matchLines []search.MatchLine) {
+	if matchLines == nil {
+		matchLines = screen.highlights()
+	}
 	matchIndex := 0
 	y := 0
+	lineNumber := screen.file.buffer.LineNumber(screen.firstLine)
 	for traverse := screen.firstLine; traverse != nil && y < screen.file.height; y++ {
 		var matchInstances []search.MatchInstance
 		if matchLines != nil && matchIndex < len(matchLines) && traverse == matchLines[matchIndex].Line {
 			matchInstances = matchLines[matchIndex].Instances
 			matchIndex++
 		}
-		if matchInstances == nil {
-			screen.drawLine(y, traverse.Data)
-		} else {
-			screen.drawLineHighlight(y, traverse.Data, matchInstances)
-		}
+		screen.drawFileLine(y, lineNumber+y, traverse, matchInstances)
 		traverse = traverse.Next
 	}
 	for y < screen.file.height {
-		screen.drawLine(y, []rune{'~'})
+		screen.drawLine(screen.file.top+y, []rune{'~'})
 		y++
 	}
 }
@@ -116,17 +188,33 @@ func (screen *Screen) displayMode() {
 	switch screen.mode {
 	case insertMode:
 		screen.clearCommand()
-		screen.putCommand(insertMessage)
+		screen.putCommand(append(append([]rune{}, insertMessage...), screen.recordingText()...))
+	case replaceMode:
+		screen.clearCommand()
+		screen.putCommand(append(append([]rune{}, replaceMessage...), screen.recordingText()...))
 	case normalMode:
 		screen.clearCommand()
+		if screen.message != nil {
+			screen.putCommand(screen.message)
+		} else if screen.input.recording != 0 {
+			screen.putCommand(screen.recordingText())
+		}
 	case commandMode:
 		screen.clearCommand()
 		screen.putCommand(screen.command.current.Data)
+		screen.drawWildmenu()
 		screen.tCell.ShowCursor(screen.command.spaceOffset, screen.command.yPosition)
 	case commandErrorMode:
 		screen.tCell.HideCursor()
 	case highlightMode:
 		// No action
+	case visualMode:
+		screen.clearCommand()
+		message := visualMessage
+		if screen.visual.linewise {
+			message = visualLineMessage
+		}
+		screen.putCommand(append(append([]rune{}, message...), screen.recordingText()...))
 	}
 	screen.tCell.Sync()
 }
@@ -139,90 +227,98 @@ func (screen *Screen) putCommand(runes []rune) {
 	screen.drawLine(screen.command.yPosition, runes)
 }
 
-func (screen *Screen) listener(quit chan struct{}) {
+func (screen *Screen) listener() {
 	for {
 		ev := screen.tCell.PollEvent()
 		switch ev := ev.(type) {
 		case *tcell.EventKey:
-			switch screen.mode {
-			case insertMode:
-				screen.executeInsertMode(ev)
-			case normalMode:
-				screen.executeNormalMode(ev)
-			case commandMode:
-				screen.executeCommandMode(ev, quit)
-			case commandErrorMode:
-				screen.mode = commandMode
-			case highlightMode:
-				screen.mode = normalMode
-				screen.completeDraw(nil)
-				screen.executeNormalMode(ev)
+			screen.message = nil
+			screen.typeKey(keymap.FromEvent(ev))
+			screen.displayMode()
+		case *timeoutEvent:
+			if ev.generation == screen.input.generation {
+				screen.resolveTypeahead(true)
+				screen.displayMode()
+			}
+		case *makeEvent:
+			if err := screen.finishMake(ev); err != nil {
+				screen.message = errorText(err)
 			}
 			screen.displayMode()
 		case *tcell.EventResize:
 			screen.updateProperties()
+			screen.followCursor()
 			screen.completeDraw(nil)
 			screen.displayMode()
 		}
 	}
 }
 
-func (screen *Screen) executeInsertMode(ev *tcell.EventKey) {
-	switch ev.Key() {
-	case tcell.KeyEsc:
+func (screen *Screen) dispatch(key keymap.Key) {
+	switch screen.mode {
+	case insertMode, replaceMode:
+		screen.executeInsertMode(key)
+	case normalMode, visualMode:
+		screen.executeNormalMode(key)
+	case commandMode:
+		screen.executeCommandMode(key)
+	case commandErrorMode:
+		screen.mode = commandMode
+	case highlightMode:
 		screen.mode = normalMode
-		screen.file.xCursor = screen.file.buffer.Left()
-		screen.drawLine(screen.file.yCursor, screen.file.buffer.Current.Data)
-	default:
-		screen.bufferAction(ev)
+		screen.completeDraw(nil)
+		screen.executeNormalMode(key)
 	}
 }
 
-func (screen *Screen) bufferAction(ev *tcell.EventKey) {
-	switch ev.Key() {
-	case tcell.KeyDown:
-		screen.actionDown()
-	case tcell.KeyUp:
-		screen.actionUp()
-	case tcell.KeyLeft:
-		screen.actionLeft()
-	case tcell.KeyRight:
-		screen.actionRight()
-	case tcell.KeyDEL:
-		screen.actionDelete()
-	case tcell.KeyEnter:
-		screen.actionKeyPress('\n')
-	default:
-		screen.actionKeyPress(ev.Rune())
-	}
-	screen.drawLine(screen.file.yCursor, screen.file.buffer.Current.Data)
+var insertBindings bindings
+
+func init() {
+	insertBindings = newBindings([]binding{
+		{"<Esc>", (*Screen).exitInsertMode},
+		{"<Down>", (*Screen).actionDown},
+		{"<Up>", (*Screen).actionUp},
+		{"<Left>", (*Screen).actionLeft},
+		{"<Right>", (*Screen).actionRight},
+		{"<BS>", (*Screen).actionDelete},
+		{"<C-h>", (*Screen).actionDelete},
+		{"<CR>", func(screen *Screen) { screen.actionKeyPress('\n') }},
+	})
 }
 
-func (screen *Screen) actionDown() {
-	possible, x := screen.file.buffer.Down(screen.mode == insertMode)
-	if !possible {
+func (screen *Screen) executeInsertMode(key keymap.Key) {
+	if screen.cmdwin != nil && key.Code == tcell.KeyEnter {
+		screen.exitInsertMode()
+		screen.runCommandWindowLine()
 		return
 	}
-	screen.file.xCursor = x
-	if screen.file.yCursor == screen.file.height-1 {
-		screen.firstLine = screen.firstLine.Next
-		screen.completeDraw(nil)
-	} else {
-		screen.file.yCursor++
+	if screen.insertion != nil && key.Code != tcell.KeyEsc {
+		screen.insertion.typed = append(screen.insertion.typed, key)
+	}
+	if action, _ := insertBindings.lookup([]keymap.Key{key}); action != nil {
+		action(screen)
+	} else if r, ok := key.Character(); ok {
+		screen.actionKeyPress(r)
 	}
+	screen.drawCurrentLine()
 }
 
-func (screen *Screen) actionUp() {
-	possible, x := screen.file.buffer.Up(screen.mode == insertMode)
-	if !possible {
-		return
+func (screen *Screen) exitInsertMode() {
+	screen.finishInsert()
+	screen.file.buffer.ClearAutoIndent()
+	screen.mode = normalMode
+	screen.file.xCursor = screen.file.buffer.Left()
+}
+
+func (screen *Screen) actionDown() {
+	if possible, _ := screen.file.buffer.Down(screen.inserting()); possible {
+		screen.followCursor()
 	}
-	screen.file.xCursor = x
-	if screen.file.yCursor == 0 {
-		screen.firstLine = screen.firstLine.Prev
-		screen.completeDraw(nil)
-	} else {
-		screen.file.yCursor--
+}
+
+func (screen *Screen) actionUp() {
+	if possible, _ := screen.file.buffer.Up(screen.inserting()); possible {
+		screen.followCursor()
 	}
 }
 
@@ -231,32 +327,31 @@ func (screen *Screen) actionLeft() {
 }
 
 func (screen *Screen) actionRight() {
-	screen.file.xCursor = screen.file.buffer.Right(screen.mode == insertMode)
+	screen.file.xCursor = screen.file.buffer.Right(screen.inserting())
 }
 
 func (screen *Screen) actionDelete() {
-	x, deletedLine := screen.file.buffer.Backspace()
-	screen.file.xCursor = x
-	if !deletedLine {
+	if screen.mode == replaceMode {
+		screen.replaceBackspace()
 		return
 	}
-	if screen.file.yCursor == 0 {
-		screen.firstLine = screen.firstLine.Prev
-	} else {
-		screen.file.yCursor--
+	x, deletedLine := screen.file.buffer.Erase()
+	screen.file.xCursor = x
+	if deletedLine {
+		screen.followCursor()
+		screen.completeDraw(nil)
 	}
-	screen.completeDraw(nil)
 }
 
 func (screen *Screen) actionKeyPress(rune rune) {
-	x, addedLine := screen.file.buffer.Add(rune)
+	if screen.mode == replaceMode {
+		screen.replaceKeyPress(rune)
+		return
+	}
+	x, addedLine := screen.file.buffer.Type(rune)
 	screen.file.xCursor = x
 	if addedLine {
-		if screen.file.yCursor == screen.file.height-1 {
-			screen.firstLine = screen.firstLine.Next
-		} else {
-			screen.file.yCursor++
-		}
+		screen.followCursor()
 		screen.completeDraw(nil)
 	}
 }
@@ -1,7 +1,72 @@
 package screen
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
 
 func TestUnimplemented(t *testing.T) {
 	// No tests
 }
+
+func TestConfigBeforeFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config := "w\nq\nwq\ne other\nnoh\nmarks\ncopen\nmake\nset tabstop=3\nnmap x dd\n"
+	if err := os.WriteFile(filepath.Join(home, configName), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(name, []byte("abc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	screen := &Screen{}
+	quit := make(chan struct{})
+	screen.Init(tcell.NewSimulationScreen(""), quit, name)
+	select {
+	case <-quit:
+		t.Fatal("config quit the editor")
+	default:
+	}
+	if expected := "-- .venrc line 1: Invalid In Config --"; string(screen.message) != expected {
+		t.Errorf("expected %q, got %q", expected, string(screen.message))
+	}
+	if screen.file.buffer.Name != name {
+		t.Errorf("expected %s to be open, got %s", name, screen.file.buffer.Name)
+	}
+	if tabStop := screen.file.buffer.Options.Number("tabstop"); tabStop != 3 {
+		t.Errorf("expected a tabstop of 3, got %d", tabStop)
+	}
+	if len(screen.mappings[normalMode].Mappings()) != 1 {
+		t.Error("expected the mapping to be made")
+	}
+}
+
+func TestLineJumpPastEnd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	name := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(name, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	screen := &Screen{}
+	screen.Init(tcell.NewSimulationScreen(""), make(chan struct{}), name)
+	file := screen.file.buffer
+	if err := screen.runCommand("100"); err != nil {
+		t.Fatalf("expected the jump to succeed, got %v", err)
+	}
+	if line := file.LineNumber(file.Current); line != 3 {
+		t.Errorf("expected to be on line 3, got %d", line)
+	}
+	if err := screen.runCommand("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := screen.runCommand("2,100d"); err != invalidRange {
+		t.Errorf("expected %v, got %v", invalidRange, err)
+	}
+	if file.Lines != 3 {
+		t.Errorf("expected no lines to be deleted, got %d lines", file.Lines)
+	}
+}
@@ -0,0 +1,180 @@
+package screen
+
+import (
+	"errors"
+
+	"github.com/bkthomps/Ven/buffer"
+	"github.com/bkthomps/Ven/search"
+)
+
+var (
+	patternNotFound   = errors.New("Pattern Not Found")
+	noPreviousPattern = errors.New("No Previous Regular Expression")
+)
+
+// searchOrigin is where the cursor and the view were when "/" or "?" was
+// typed. Incremental search moves away from it while the pattern is typed,
+// and leaving the command line without searching goes back to it.
+type searchOrigin struct {
+	cursor    buffer.Position
+	firstLine *buffer.Line
+}
+
+func (screen *Screen) startSearch() {
+	screen.searchOrigin = &searchOrigin{cursor: screen.file.buffer.Cursor(), firstLine: screen.firstLine}
+}
+
+func (screen *Screen) returnToOrigin(origin *searchOrigin) {
+	screen.file.buffer.SetCursor(origin.cursor, false)
+	screen.firstLine = origin.firstLine
+	screen.followCursor()
+}
+
+// incrementalSearch runs after each key on the command line of a search.
+// With incsearch, the cursor goes to the match of what has been typed so
+// far and the matches on the screen are highlighted, while a pattern which
+// is not finished or has no match shows the view from before the search.
+func (screen *Screen) incrementalSearch() {
+	origin := screen.searchOrigin
+	if origin == nil {
+		return
+	}
+	if screen.mode != commandMode {
+		screen.searchOrigin = nil
+		screen.returnToOrigin(origin)
+		screen.completeDraw(nil)
+		return
+	}
+	if !screen.options.Bool("incsearch") {
+		return
+	}
+	screen.returnToOrigin(origin)
+	text := string(screen.command.current.Data[1:])
+	if text == "" {
+		screen.completeDraw(nil)
+		return
+	}
+	pattern, err := screen.searchPattern(text)
+	if err != nil {
+		screen.completeDraw(nil)
+		return
+	}
+	match, found, err := search.Find(pattern, origin.cursor, screen.command.current.Data[0] == '?')
+	if err != nil || !found {
+		screen.completeDraw(nil)
+		return
+	}
+	screen.file.buffer.SetCursor(buffer.Position{Line: match.Line, Offset: match.Offset}, false)
+	screen.followCursor()
+	screen.completeDraw(screen.visibleMatches(pattern))
+}
+
+// runSearch is enter on the command line of "/" or "?", which moves the
+// cursor to the next or previous match, and highlights the matches on the
+// screen until the next key. An empty pattern uses the last search.
+func (screen *Screen) runSearch() {
+	if origin := screen.searchOrigin; origin != nil {
+		screen.searchOrigin = nil
+		screen.returnToOrigin(origin)
+		screen.completeDraw(nil)
+	}
+	text := string(screen.command.current.Data[1:])
+	if text == "" {
+		searches := screen.histories['/']
+		if len(searches) == 0 {
+			screen.searchFailed(noPreviousPattern)
+			return
+		}
+		text = searches[len(searches)-1]
+	}
+	pattern, err := screen.searchPattern(text)
+	if err != nil {
+		screen.searchFailed(err)
+		return
+	}
+	backward := screen.command.current.Data[0] == '?'
+	match, found, err := search.Find(pattern, screen.file.buffer.Cursor(), backward)
+	if err != nil {
+		screen.searchFailed(badRegex)
+		return
+	}
+	if !found {
+		screen.searchFailed(patternNotFound)
+		return
+	}
+	screen.noHighlight = false
+	from := screen.here()
+	screen.file.buffer.SetCursor(buffer.Position{Line: match.Line, Offset: match.Offset}, false)
+	screen.recordJump(from)
+	screen.followCursor()
+	screen.mode = highlightMode
+	screen.completeDraw(screen.visibleMatches(pattern))
+}
+
+// searchPattern translates a search pattern as typed into a regular
+// expression, applying the ignorecase and smartcase options.
+func (screen *Screen) searchPattern(pattern string) (string, error) {
+	return search.Translate(pattern, search.Options{
+		IgnoreCase: screen.options.Bool("ignorecase"),
+		SmartCase:  screen.options.Bool("smartcase"),
+	})
+}
+
+// searchFailed shows the error, after which the search can be edited on
+// the command line, so incremental search starts again from here.
+func (screen *Screen) searchFailed(err error) {
+	screen.startSearch()
+	screen.displayError(err)
+}
+
+// highlightPattern is the last search pattern while hlsearch is set and
+// the highlighting has not been turned off with :nohlsearch.
+func (screen *Screen) highlightPattern() (string, bool) {
+	searches := screen.histories['/']
+	if !screen.options.Bool("hlsearch") || screen.noHighlight || len(searches) == 0 {
+		return "", false
+	}
+	pattern, err := screen.searchPattern(searches[len(searches)-1])
+	return pattern, err == nil
+}
+
+// highlights are the matches of the last search on the screen, which are
+// found again each time the screen is drawn, so they follow scrolling and
+// changes to the file.
+func (screen *Screen) highlights() []search.MatchLine {
+	pattern, ok := screen.highlightPattern()
+	if !ok {
+		return nil
+	}
+	return screen.visibleMatches(pattern)
+}
+
+// lineHighlights are the matches of the last search in one line, for when
+// only that line is drawn again.
+func (screen *Screen) lineHighlights(line *buffer.Line) []search.MatchInstance {
+	pattern, ok := screen.highlightPattern()
+	if !ok {
+		return nil
+	}
+	matches, _, err := search.AllMatches(pattern, line, 1)
+	if err != nil || len(matches) == 0 || matches[0].Line != line {
+		return nil
+	}
+	return matches[0].Instances
+}
+
+// nohlsearchCommand turns off the highlighting of hlsearch until the next
+// search.
+func (screen *Screen) nohlsearchCommand(lines lineRange, bang bool, arguments string) error {
+	screen.noHighlight = true
+	screen.completeDraw(nil)
+	return nil
+}
+
+func (screen *Screen) visibleMatches(pattern string) []search.MatchLine {
+	matches, _, err := search.AllMatches(pattern, screen.firstLine, screen.file.height)
+	if err != nil {
+		return nil
+	}
+	return matches
+}
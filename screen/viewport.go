@@ -0,0 +1,251 @@
+package screen
+
+import (
+	"strconv"
+
+	"github.com/bkthomps/Ven/buffer"
+)
+
+// The viewport is the lines of the file which are on the screen, starting
+// at firstLine. Scrolling moves the viewport and keeps the cursor on the
+// screen, while moving the cursor scrolls the viewport to follow it. Both
+// keep the cursor scrolloff lines away from the top and bottom of the
+// screen where the file allows it.
+
+// scrollOffset is the scrolloff option, limited so that the cursor still
+// fits between the margins.
+func (screen *Screen) scrollOffset() int {
+	margin := screen.options.Number("scrolloff")
+	if limit := (screen.file.height - 1) / 2; margin > limit {
+		margin = limit
+	}
+	if margin < 0 {
+		margin = 0
+	}
+	return margin
+}
+
+// followCursor places the screen cursor on the cursor of the file,
+// scrolling if the line is not visible or is within the margin.
+func (screen *Screen) followCursor() {
+	file := screen.file.buffer
+	screen.file.xCursor = file.XPosition()
+	first := file.LineNumber(screen.firstLine)
+	current := file.LineNumber(file.Current)
+	margin := screen.scrollOffset()
+	top := first
+	if current-margin < top {
+		top = current - margin
+	}
+	if current+margin > top+screen.file.height-1 {
+		top = current + margin - screen.file.height + 1
+		limit := file.Lines - screen.file.height + 1
+		if limit < first {
+			limit = first
+		}
+		if top > limit {
+			top = limit
+		}
+	}
+	screen.setTop(top)
+}
+
+// setTop scrolls so that the line with the one-based number is at the top
+// of the screen, and places the screen cursor.
+func (screen *Screen) setTop(top int) {
+	file := screen.file.buffer
+	if top < 1 {
+		top = 1
+	}
+	if top > file.Lines {
+		top = file.Lines
+	}
+	screen.file.xCursor = file.XPosition()
+	screen.file.yCursor = file.LineNumber(file.Current) - top
+	if file.LineNumber(screen.firstLine) == top {
+		return
+	}
+	screen.firstLine = file.LineAt(top)
+	screen.completeDraw(nil)
+}
+
+// keepCursorOnScreen moves the cursor up or down to the nearest line which
+// is on the screen and outside of the margin.
+func (screen *Screen) keepCursorOnScreen() {
+	file := screen.file.buffer
+	margin := screen.scrollOffset()
+	top := file.LineNumber(screen.firstLine)
+	bottom := top + screen.file.height - 1
+	highest := top + margin
+	if top == 1 {
+		highest = 1
+	}
+	lowest := bottom - margin
+	if bottom >= file.Lines {
+		lowest = file.Lines
+	}
+	if highest > lowest {
+		highest = lowest
+	}
+	current := file.LineNumber(file.Current)
+	if current < highest {
+		screen.moveCursorLines(highest - current)
+	} else if current > lowest {
+		screen.moveCursorLines(lowest - current)
+	}
+	screen.setTop(top)
+}
+
+// moveCursorLines moves the cursor down by the number of lines, or up if
+// it is negative, keeping the column as j and k do.
+func (screen *Screen) moveCursorLines(lines int) {
+	for ; lines > 0; lines-- {
+		screen.file.buffer.Down(false)
+	}
+	for ; lines < 0; lines++ {
+		screen.file.buffer.Up(false)
+	}
+}
+
+func (screen *Screen) moveCursorToFirstNonBlank() {
+	line := screen.file.buffer.Current
+	screen.file.buffer.SetCursor(buffer.Position{Line: line, Offset: line.FirstNonBlank()}, false)
+}
+
+// scrollLines is ctrl-e, which scrolls down by the number of lines until
+// the last line is at the top, or ctrl-y when it is negative.
+func (screen *Screen) scrollLines(lines int) {
+	top := screen.file.buffer.LineNumber(screen.firstLine)
+	target := top + lines
+	if target < 1 {
+		target = 1
+	}
+	if target > screen.file.buffer.Lines {
+		target = screen.file.buffer.Lines
+	}
+	if target == top {
+		screen.fail()
+		return
+	}
+	screen.setTop(target)
+	screen.keepCursorOnScreen()
+}
+
+// scrollAmount is how many lines ctrl-d and ctrl-u scroll. A count sets
+// the scroll option, which is half of the screen when it is zero.
+func (screen *Screen) scrollAmount(count int) int {
+	if count > 0 {
+		_, _ = screen.options.Set("scroll=" + strconv.Itoa(count))
+	}
+	if amount := screen.options.Number("scroll"); amount > 0 {
+		return amount
+	}
+	if screen.file.height < 2 {
+		return 1
+	}
+	return screen.file.height / 2
+}
+
+// halfPageDown is ctrl-d, which scrolls down and moves the cursor down by
+// the same number of lines. Once the end of the file is on the screen, it
+// only moves the cursor.
+func (screen *Screen) halfPageDown(count int) {
+	file := screen.file.buffer
+	if file.Current.Next == nil {
+		screen.fail()
+		return
+	}
+	amount := screen.scrollAmount(count)
+	top := file.LineNumber(screen.firstLine)
+	screen.moveCursorLines(amount)
+	if limit := file.Lines - screen.file.height + 1; top+amount > limit {
+		amount = limit - top
+	}
+	if amount > 0 {
+		screen.setTop(top + amount)
+	}
+	screen.keepCursorOnScreen()
+}
+
+// halfPageUp is ctrl-u, which scrolls up and moves the cursor up by the
+// same number of lines.
+func (screen *Screen) halfPageUp(count int) {
+	file := screen.file.buffer
+	if file.Current == file.First {
+		screen.fail()
+		return
+	}
+	amount := screen.scrollAmount(count)
+	top := file.LineNumber(screen.firstLine)
+	screen.moveCursorLines(-amount)
+	screen.setTop(top - amount)
+	screen.keepCursorOnScreen()
+}
+
+// pageForward is ctrl-f, which scrolls down by a screen less two lines,
+// so that the lines at the bottom stay on the screen.
+func (screen *Screen) pageForward(count int) {
+	file := screen.file.buffer
+	top := file.LineNumber(screen.firstLine)
+	if top == file.Lines {
+		screen.fail()
+		return
+	}
+	screen.setTop(top + counted(count)*screen.pageAmount())
+	screen.keepCursorOnScreen()
+	screen.moveCursorToFirstNonBlank()
+}
+
+// pageBackward is ctrl-b, which scrolls up by a screen less two lines.
+func (screen *Screen) pageBackward(count int) {
+	file := screen.file.buffer
+	top := file.LineNumber(screen.firstLine)
+	if top == 1 {
+		screen.fail()
+		return
+	}
+	screen.setTop(top - counted(count)*screen.pageAmount())
+	screen.keepCursorOnScreen()
+	screen.moveCursorToFirstNonBlank()
+}
+
+func (screen *Screen) pageAmount() int {
+	if screen.file.height <= 2 {
+		return 1
+	}
+	return screen.file.height - 2
+}
+
+type cursorPlacement int
+
+const (
+	cursorAtTop cursorPlacement = iota
+	cursorAtMiddle
+	cursorAtBottom
+)
+
+// placeCursorLine is "zt", "zz" or "zb", which scroll so that the cursor
+// line is at the top, middle or bottom of the screen. With a count, the
+// cursor first goes to that line. The forms which are "z<CR>", "z." and
+// "z-" also move the cursor to the first non-blank rune.
+func placeCursorLine(placement cursorPlacement, toFirstNonBlank bool) func(screen *Screen, count int) {
+	return func(screen *Screen, count int) {
+		file := screen.file.buffer
+		if count > 0 {
+			file.SetCursor(buffer.Position{Line: file.LineAt(count), Offset: file.Cursor().Offset}, false)
+		}
+		if toFirstNonBlank {
+			screen.moveCursorToFirstNonBlank()
+		}
+		current := file.LineNumber(file.Current)
+		margin := screen.scrollOffset()
+		switch placement {
+		case cursorAtTop:
+			screen.setTop(current - margin)
+		case cursorAtMiddle:
+			screen.setTop(current - (screen.file.height-1)/2)
+		case cursorAtBottom:
+			screen.setTop(current - screen.file.height + 1 + margin)
+		}
+	}
+}
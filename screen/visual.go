@@ -0,0 +1,264 @@
+package screen
+
+import (
+	"unicode"
+
+	"github.com/bkthomps/Ven/buffer"
+	"github.com/bkthomps/Ven/keymap"
+)
+
+var (
+	visualMessage     = []rune("-- VISUAL --")
+	visualLineMessage = []rune("-- VISUAL LINE --")
+)
+
+// visual is the selection of visual mode, which is from the anchor to
+// the cursor, including both of them.
+type visual struct {
+	anchor   buffer.Position
+	linewise bool
+}
+
+type object func(file *buffer.File, count int) (buffer.Range, bool)
+
+// objectTable holds the text objects, which follow an operator or are
+// typed in visual mode.
+var objectTable normalBindings
+
+// visualTable holds the bindings which are only in visual mode. Other
+// keys are looked up as text objects, motions and operators.
+var visualTable normalBindings
+
+func init() {
+	objects := []normalBinding{
+		{keys: "iw", object: wordObject(false, false)},
+		{keys: "aw", object: wordObject(true, false)},
+		{keys: "iW", object: wordObject(false, true)},
+		{keys: "aW", object: wordObject(true, true)},
+		{keys: "is", object: func(file *buffer.File, count int) (buffer.Range, bool) { return file.SentenceObject(count, false) }},
+		{keys: "as", object: func(file *buffer.File, count int) (buffer.Range, bool) { return file.SentenceObject(count, true) }},
+		{keys: "ip", object: func(file *buffer.File, count int) (buffer.Range, bool) { return file.ParagraphObject(count, false) }},
+		{keys: "ap", object: func(file *buffer.File, count int) (buffer.Range, bool) { return file.ParagraphObject(count, true) }},
+		{keys: "it", object: func(file *buffer.File, count int) (buffer.Range, bool) { return file.TagObject(count, false) }},
+		{keys: "at", object: func(file *buffer.File, count int) (buffer.Range, bool) { return file.TagObject(count, true) }},
+	}
+	for _, quote := range []rune{'"', '\'', '`'} {
+		objects = append(objects,
+			normalBinding{keys: "i" + string(quote), object: quoteObject(quote, false)},
+			normalBinding{keys: "a" + string(quote), object: quoteObject(quote, true)})
+	}
+	brackets := []struct {
+		open, close rune
+		names       string
+	}{
+		{'(', ')', "()b"},
+		{'{', '}', "{}B"},
+		{'[', ']', "[]"},
+		{'<', '>', "<>"},
+	}
+	for _, bracket := range brackets {
+		for _, name := range bracket.names {
+			objects = append(objects,
+				normalBinding{keys: "i" + string(name), object: bracketObject(bracket.open, bracket.close, false)},
+				normalBinding{keys: "a" + string(name), object: bracketObject(bracket.open, bracket.close, true)})
+		}
+	}
+	objectTable = newNormalBindings(objects)
+	visualTable = newNormalBindings([]normalBinding{
+		{keys: "<Esc>", action: func(screen *Screen, count int) { screen.exitVisual() }},
+		{keys: "v", action: func(screen *Screen, count int) { screen.switchVisual(false) }},
+		{keys: "V", action: func(screen *Screen, count int) { screen.switchVisual(true) }},
+		{keys: "o", action: (*Screen).swapVisualEnds},
+		{keys: "x", alias: "d"},
+		{keys: "s", alias: "c"},
+		{keys: "X", action: visualLines((*Screen).deleteOperator)},
+		{keys: "D", action: visualLines((*Screen).deleteOperator)},
+		{keys: "Y", action: visualLines((*Screen).yankOperator)},
+		{keys: "C", action: visualLines((*Screen).changeOperator)},
+		{keys: "S", action: visualLines((*Screen).changeOperator)},
+		{keys: "R", action: visualLines((*Screen).changeOperator)},
+		{keys: "r", action: (*Screen).replaceSelection, argument: true},
+		{keys: "J", action: visualLines(joinOperator(false))},
+		{keys: "gJ", action: visualLines(joinOperator(true))},
+		{keys: "u", operator: transformOperator(unicode.ToLower)},
+		{keys: "U", operator: transformOperator(unicode.ToUpper)},
+		{keys: "~", operator: transformOperator(toggleCase)},
+		{keys: ":", action: (*Screen).visualCommand},
+		{keys: "/", action: func(screen *Screen, count int) { screen.exitVisual(); screen.startCommand('/') }},
+		{keys: "?", action: func(screen *Screen, count int) { screen.exitVisual(); screen.startCommand('?') }},
+	})
+}
+
+func wordObject(around, bigWord bool) object {
+	return func(file *buffer.File, count int) (buffer.Range, bool) {
+		return file.WordObject(count, around, bigWord)
+	}
+}
+
+func quoteObject(quote rune, around bool) object {
+	return func(file *buffer.File, count int) (buffer.Range, bool) {
+		return file.QuoteObject(quote, count, around)
+	}
+}
+
+func bracketObject(open, close rune, around bool) object {
+	return func(file *buffer.File, count int) (buffer.Range, bool) {
+		return file.BracketObject(open, close, count, around)
+	}
+}
+
+// lookupNormal finds the binding of the keys in normal or visual mode.
+// Text objects come first when an operator is waiting or in visual mode,
+// and visual mode only uses the motions and operators of normal mode.
+func (screen *Screen) lookupNormal(keys []keymap.Key) (bound *normalBinding, isPrefix bool) {
+	if screen.mode == visualMode {
+		if bound, isPrefix = visualTable.lookup(keys); bound != nil || isPrefix {
+			return bound, isPrefix
+		}
+	}
+	if screen.mode == visualMode || screen.pending.operator != nil {
+		if bound, isPrefix = objectTable.lookup(keys); bound != nil || isPrefix {
+			return bound, isPrefix
+		}
+	}
+	bound, isPrefix = normalTable.lookup(keys)
+	if screen.mode == visualMode && bound != nil && bound.motion == nil && bound.operator == nil {
+		return nil, isPrefix
+	}
+	return bound, isPrefix
+}
+
+func (screen *Screen) startVisual(linewise bool) {
+	screen.mode = visualMode
+	screen.visual = visual{anchor: screen.file.buffer.Cursor(), linewise: linewise}
+}
+
+func (screen *Screen) exitVisual() {
+	screen.setVisualMarks()
+	screen.mode = normalMode
+	screen.completeDraw(nil)
+}
+
+// visualCommand is ":" in visual mode, which starts an ex command on the
+// lines of the selection.
+func (screen *Screen) visualCommand(count int) {
+	screen.exitVisual()
+	screen.startCommand(':')
+	for _, r := range "'<,'>" {
+		screen.commandInsert(r)
+	}
+}
+
+// switchVisual is "v" or "V" in visual mode, which leaves visual mode if
+// it is already of that kind, and otherwise changes to that kind.
+func (screen *Screen) switchVisual(linewise bool) {
+	if screen.visual.linewise == linewise {
+		screen.exitVisual()
+		return
+	}
+	screen.visual.linewise = linewise
+}
+
+func (screen *Screen) swapVisualEnds(count int) {
+	cursor := screen.file.buffer.Cursor()
+	screen.file.buffer.SetCursor(screen.visual.anchor, false)
+	screen.visual.anchor = cursor
+}
+
+func visualLines(operator func(screen *Screen, r region)) func(screen *Screen, count int) {
+	return func(screen *Screen, count int) {
+		screen.visual.linewise = true
+		screen.operateOnSelection(operator)
+	}
+}
+
+// selection is the region which visual mode has selected.
+func (screen *Screen) selection() region {
+	start, end := screen.visual.anchor, screen.file.buffer.Cursor()
+	if screen.file.buffer.Compare(start, end) > 0 {
+		start, end = end, start
+	}
+	if screen.visual.linewise {
+		return screen.linesRegion(start.Line, end.Line)
+	}
+	end.Offset++
+	return region{start: start, end: end}
+}
+
+func (screen *Screen) operateOnSelection(operator func(screen *Screen, r region)) {
+	r := screen.selection()
+	screen.exitVisual()
+	operator(screen, r)
+}
+
+// selectObject selects a text object in visual mode. If part of the text
+// is already selected, the selection grows to the next larger object, so
+// that repeating "iw" or "i(" selects more.
+func (screen *Screen) selectObject(selected object, count int) bool {
+	file := screen.file.buffer
+	count = counted(count)
+	r, ok := selected(file, count)
+	if screen.visual.anchor != file.Cursor() {
+		current := screen.selection()
+		for tries := 0; ok && tries < maxMapDepth; tries++ {
+			if file.Compare(current.start, r.Start) < 0 {
+				r.Start = current.start
+			}
+			if file.Compare(current.end, r.End) > 0 {
+				r.End = current.end
+			}
+			if r.Start != current.start || r.End != current.end {
+				break
+			}
+			count++
+			r, ok = selected(file, count)
+		}
+	}
+	if !ok {
+		return false
+	}
+	if r.Linewise {
+		screen.visual.linewise = true
+	}
+	screen.visual.anchor = r.Start
+	last := r.End
+	if r.Start != r.End && !r.Linewise {
+		last = before(r.End)
+	}
+	file.SetCursor(last, false)
+	return true
+}
+
+func before(position buffer.Position) buffer.Position {
+	if position.Offset > 0 {
+		position.Offset--
+	} else if position.Line.Prev != nil {
+		position = buffer.Position{Line: position.Line.Prev, Offset: len(position.Line.Prev.Data)}
+	}
+	return position
+}
+
+// selectedOffsets returns the offsets of the line which are selected in
+// visual mode, from start up to, but not including, end.
+func (screen *Screen) selectedOffsets(line *buffer.Line) (start, end int) {
+	if screen.mode != visualMode {
+		return 0, 0
+	}
+	file := screen.file.buffer
+	r := screen.selection()
+	here := buffer.Position{Line: line}
+	if file.Compare(here, buffer.Position{Line: r.start.Line}) < 0 || file.Compare(here, buffer.Position{Line: r.end.Line}) > 0 {
+		return 0, 0
+	}
+	start, end = 0, len(line.Data)
+	if line == r.start.Line && !r.linewise {
+		start = r.start.Offset
+	}
+	if line == r.end.Line && !r.linewise {
+		end = r.end.Offset
+	}
+	if end == start {
+		end = start + 1
+	}
+	return start, end
+}
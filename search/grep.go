@@ -0,0 +1,146 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// binaryPrefix is how much of a file is looked at for a null byte, which
+// means that it is binary and is not searched, as in git.
+const binaryPrefix = 8000
+
+// Hit is the first match of a pattern in a line of a file, where the line
+// and the rune column count from 1.
+type Hit struct {
+	Path   string
+	Line   int
+	Column int
+	Text   string
+}
+
+// Grep searches the files under the directory for the pattern, sorted by
+// path and line. The .git directory and the paths ignored by .gitignore
+// files are skipped, and the files are searched by a pool of goroutines.
+func Grep(directory, pattern string) ([]Hit, error) {
+	re, err := regexp.Compile("(?m)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	if _, err := os.Stat(directory); err != nil {
+		return nil, err
+	}
+	paths := make(chan string)
+	results := make(chan []Hit)
+	var workers sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for name := range paths {
+				if hits := grepFile(re, name); len(hits) > 0 {
+					results <- hits
+				}
+			}
+		}()
+	}
+	go func() {
+		walk(directory, paths)
+		close(paths)
+		workers.Wait()
+		close(results)
+	}()
+	hits := make([]Hit, 0)
+	for found := range results {
+		hits = append(hits, found...)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Path != hits[j].Path {
+			return hits[i].Path < hits[j].Path
+		}
+		return hits[i].Line < hits[j].Line
+	})
+	return hits, nil
+}
+
+// walk sends the path of each file under the directory which is not
+// ignored.
+func walk(directory string, paths chan<- string) {
+	rules := make(ignoreRules)
+	_ = filepath.WalkDir(directory, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		relative, err := filepath.Rel(directory, name)
+		if err != nil {
+			return nil
+		}
+		relative = filepath.ToSlash(relative)
+		if entry.IsDir() {
+			if relative == "." {
+				rules.read(directory, "")
+				return nil
+			}
+			if entry.Name() == ".git" || rules.ignored(relative, true) {
+				return filepath.SkipDir
+			}
+			rules.read(directory, relative)
+			return nil
+		}
+		if !rules.ignored(relative, false) {
+			paths <- name
+		}
+		return nil
+	})
+}
+
+// grepFile returns the hits in a file, or none if it cannot be read or is
+// binary.
+func grepFile(re *regexp.Regexp, name string) []Hit {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	prefix := data
+	if len(prefix) > binaryPrefix {
+		prefix = prefix[:binaryPrefix]
+	}
+	if bytes.IndexByte(prefix, 0) >= 0 {
+		return nil
+	}
+	text := string(data)
+	hits := make([]Hit, 0)
+	line, lineStart := 1, 0
+	for _, pair := range re.FindAllStringIndex(text, -1) {
+		if pair[0] == len(text) && len(text) > 0 && text[len(text)-1] == '\n' {
+			break
+		}
+		newLines := strings.Count(text[lineStart:pair[0]], "\n")
+		if newLines == 0 && len(hits) > 0 && hits[len(hits)-1].Line == line {
+			continue
+		}
+		if newLines > 0 {
+			line += newLines
+			lineStart += strings.LastIndex(text[lineStart:pair[0]], "\n") + 1
+		}
+		lineEnd := strings.IndexByte(text[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text) - lineStart
+		}
+		hits = append(hits, Hit{
+			Path:   name,
+			Line:   line,
+			Column: utf8.RuneCountInString(text[lineStart:pair[0]]) + 1,
+			Text:   strings.TrimSpace(text[lineStart : lineStart+lineEnd]),
+		})
+	}
+	return hits
+}
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(directory, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+func TestGrep(t *testing.T) {
+	directory := writeFiles(t, map[string]string{
+		"a.go":       "package a\n\nfunc 汉 needle() { needle }\n",
+		"b/b.go":     "needle\nhay\n  needle\n",
+		"c.txt":      "hay\n",
+		"binary.bin": "needle\x00",
+	})
+	hits, err := Grep(directory, "needle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Hit{
+		{Path: filepath.Join(directory, "a.go"), Line: 3, Column: 8, Text: "func 汉 needle() { needle }"},
+		{Path: filepath.Join(directory, "b", "b.go"), Line: 1, Column: 1, Text: "needle"},
+		{Path: filepath.Join(directory, "b", "b.go"), Line: 3, Column: 3, Text: "needle"},
+	}
+	if len(hits) != len(expected) {
+		t.Fatalf("expected %d hits, got %+v", len(expected), hits)
+	}
+	for i := range expected {
+		if hits[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], hits[i])
+		}
+	}
+}
+
+func TestGrepAcrossLines(t *testing.T) {
+	directory := writeFiles(t, map[string]string{"a": "ab\ncd\nab\nce\n"})
+	hits, err := Grep(directory, `b\nc[de]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].Line != 1 || hits[1].Line != 3 || hits[1].Column != 2 {
+		t.Errorf("bad hits: %+v", hits)
+	}
+}
+
+func TestGrepIgnored(t *testing.T) {
+	directory := writeFiles(t, map[string]string{
+		".gitignore":         "*.log\n/build/\n!keep.log\n# comment\n",
+		".git/config":        "needle",
+		"a.log":              "needle",
+		"keep.log":           "needle",
+		"build/out":          "needle",
+		"src/build/in":       "needle",
+		"src/.gitignore":     "generated\n**/deep/*.go\n",
+		"src/generated":      "needle",
+		"src/x/deep/a.go":    "needle",
+		"src/x/deep/a.txt":   "needle",
+		"src/x/generated/in": "needle",
+	})
+	hits, err := Grep(directory, "needle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"keep.log", "src/build/in", "src/x/deep/a.txt"}
+	if len(hits) != len(expected) {
+		t.Fatalf("expected %d hits, got %+v", len(expected), hits)
+	}
+	for i, name := range expected {
+		if hits[i].Path != filepath.Join(directory, filepath.FromSlash(name)) {
+			t.Errorf("expected %s, got %s", name, hits[i].Path)
+		}
+	}
+}
+
+func TestGrepMissingDirectory(t *testing.T) {
+	if _, err := Grep(filepath.Join(t.TempDir(), "missing"), "a"); err == nil {
+		t.Error("expected an error")
+	}
+}
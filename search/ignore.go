@@ -0,0 +1,127 @@
+package search
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const ignoreFileName = ".gitignore"
+
+// ignoreRule is a line of a .gitignore file, which applies to the paths
+// under the directory of the file, given as a slash-separated path from
+// the directory being searched. A rule with a slash before its end is
+// anchored to that directory, while one without matches a name at any
+// depth.
+type ignoreRule struct {
+	directory     string
+	segments      []string
+	anchored      bool
+	negated       bool
+	directoryOnly bool
+}
+
+// ignoreRules holds the rules of each directory which has a .gitignore
+// file, where the rules of deeper directories come later.
+type ignoreRules map[string][]ignoreRule
+
+func (rules ignoreRules) read(root, directory string) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(directory), ignoreFileName))
+	if err != nil {
+		return
+	}
+	if parsed := parseIgnore(directory, string(data)); len(parsed) > 0 {
+		rules[directory] = parsed
+	}
+}
+
+func parseIgnore(directory, data string) []ignoreRule {
+	rules := make([]ignoreRule, 0)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{directory: directory}
+		if strings.HasPrefix(line, "!") {
+			rule.negated = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.directoryOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ignored reports whether a slash-separated path from the directory being
+// searched is ignored, where the last rule which matches it decides.
+func (rules ignoreRules) ignored(name string, isDirectory bool) bool {
+	ignored := false
+	directories := append([]string{""}, strings.Split(path.Dir(name), "/")...)
+	for i := range directories {
+		directory := strings.Join(directories[1:i+1], "/")
+		if directory == "." {
+			continue
+		}
+		for _, rule := range rules[directory] {
+			if rule.matches(name, isDirectory) {
+				ignored = !rule.negated
+			}
+		}
+	}
+	return ignored
+}
+
+func (rule ignoreRule) matches(name string, isDirectory bool) bool {
+	if rule.directoryOnly && !isDirectory {
+		return false
+	}
+	if rule.directory != "" {
+		if !strings.HasPrefix(name, rule.directory+"/") {
+			return false
+		}
+		name = name[len(rule.directory)+1:]
+	}
+	parts := strings.Split(name, "/")
+	if !rule.anchored {
+		parts = parts[len(parts)-1:]
+	}
+	return matchSegments(rule.segments, parts)
+}
+
+// matchSegments matches the parts of a path against the segments of a
+// pattern, where a segment of "**" matches any number of parts.
+func matchSegments(segments, parts []string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+	if segments[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(segments[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if matched, err := path.Match(segments[0], parts[0]); err != nil || !matched {
+		return false
+	}
+	return matchSegments(segments[1:], parts[1:])
+}
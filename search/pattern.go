@@ -0,0 +1,504 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Options are the settings which change how a pattern matches.
+type Options struct {
+	IgnoreCase bool
+	SmartCase  bool
+}
+
+// The magic levels of a pattern, which are set by "\v", "\m", "\M" and
+// "\V". The higher the level, the more characters are special without a
+// backslash before them.
+const (
+	veryNoMagic = iota
+	noMagic
+	magic
+	veryMagic
+)
+
+var (
+	unmatchedOpen    = errors.New("Unmatched \\(")
+	unmatchedClose   = errors.New("Unmatched \\)")
+	noSubstitute     = errors.New("No Previous Substitute Regular Expression")
+	badBraces        = errors.New("Invalid Count In \\{")
+	unsupportedMulti = errors.New("Lookaround With \\@ Is Not Supported")
+	wordStart        = errors.New("\\< Must Be Followed By A Word Character")
+	wordEnd          = errors.New("\\> Must Follow A Word Character")
+)
+
+// specialLevel is the lowest magic level where the character is special
+// without a backslash. In lower levels it is special after a backslash.
+var specialLevel = map[rune]int{
+	'^': noMagic,
+	'$': noMagic,
+	'.': magic,
+	'*': magic,
+	'[': magic,
+	'~': magic,
+	'(': veryMagic,
+	')': veryMagic,
+	'|': veryMagic,
+	'+': veryMagic,
+	'?': veryMagic,
+	'=': veryMagic,
+	'{': veryMagic,
+	'@': veryMagic,
+	'<': veryMagic,
+	'>': veryMagic,
+	'%': veryMagic,
+	'&': veryMagic,
+}
+
+// classes are the character classes which are a letter after a backslash.
+// As in vim, none of them match a line break, unless "\_" is put before the
+// letter.
+var classes = map[rune]string{
+	's': `[ \t]`,
+	'S': `[^ \t\n]`,
+	'd': `[0-9]`,
+	'D': `[^0-9\n]`,
+	'w': `[0-9A-Za-z_]`,
+	'W': `[^0-9A-Za-z_\n]`,
+	'a': `[A-Za-z]`,
+	'A': `[^A-Za-z\n]`,
+	'l': `[a-z]`,
+	'L': `[^a-z\n]`,
+	'u': `[A-Z]`,
+	'U': `[^A-Z\n]`,
+	'x': `[0-9A-Fa-f]`,
+	'X': `[^0-9A-Fa-f\n]`,
+	'o': `[0-7]`,
+	'O': `[^0-7\n]`,
+	'h': `[A-Za-z_]`,
+	'H': `[^A-Za-z_\n]`,
+	't': `\t`,
+	'e': `\x1b`,
+	'r': `\r`,
+	'n': `\n`,
+}
+
+// wordClasses are the classes which only match word characters.
+const wordClasses = "wdaluhxo"
+
+// Translate turns a vim pattern into the syntax of the regexp package.
+// As in vim, "\c" and "\C" anywhere in the pattern make it ignore case or
+// match case, and otherwise smartcase only ignores case when the pattern
+// has no uppercase letters.
+func Translate(pattern string, options Options) (string, error) {
+	t := translator{runes: []rune(pattern), level: magic}
+	if err := t.translate(); err != nil {
+		return "", err
+	}
+	ignoreCase := options.IgnoreCase
+	if ignoreCase && options.SmartCase && t.hasUpper {
+		ignoreCase = false
+	}
+	if t.caseFlag != 0 {
+		ignoreCase = t.caseFlag == 'c'
+	}
+	translated := t.builder.String()
+	if ignoreCase {
+		translated = "(?i)" + translated
+	}
+	if _, err := regexp.Compile(translated); err != nil {
+		return "", compileError(err)
+	}
+	return translated, nil
+}
+
+// compileError makes an error of the regexp package read like the other
+// errors of the editor, such as "Invalid Repeat Count: {2000}".
+func compileError(err error) error {
+	var syntaxError *syntax.Error
+	if !errors.As(err, &syntaxError) {
+		return err
+	}
+	words := strings.Fields(string(syntaxError.Code))
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return fmt.Errorf("%s: %s", strings.Join(words, " "), syntaxError.Expr)
+}
+
+type translator struct {
+	runes    []rune
+	index    int
+	level    int
+	builder  strings.Builder
+	depth    int
+	hasAtom  bool
+	word     bool
+	wasWord  bool
+	hasUpper bool
+	caseFlag rune
+}
+
+func (t *translator) translate() error {
+	for t.index < len(t.runes) {
+		r := t.runes[t.index]
+		t.index++
+		escaped := false
+		if r == '\\' && t.index < len(t.runes) {
+			r = t.runes[t.index]
+			t.index++
+			escaped = true
+		}
+		t.wasWord, t.word = t.word, false
+		level, hasLevel := specialLevel[r]
+		special := hasLevel && (t.level >= level) != escaped
+		var err error
+		switch {
+		case escaped && (isLetter(r) || r == '_'):
+			err = t.escapedLetter(r)
+		case special:
+			err = t.special(r)
+		default:
+			if unicode.IsUpper(r) {
+				t.hasUpper = true
+			}
+			t.literal(r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if t.depth > 0 {
+		return unmatchedOpen
+	}
+	return nil
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// isWordRune is whether the rune is a word character to "\b", which only
+// knows of ASCII ones.
+func isWordRune(r rune) bool {
+	return isLetter(r) || (r >= '0' && r <= '9') || r == '_'
+}
+
+func (t *translator) literal(r rune) {
+	t.builder.WriteString(regexp.QuoteMeta(string(r)))
+	t.hasAtom = true
+	t.word = isWordRune(r)
+}
+
+func (t *translator) escapedLetter(r rune) error {
+	switch r {
+	case 'v':
+		t.level = veryMagic
+		t.word = t.wasWord
+	case 'm':
+		t.level = magic
+		t.word = t.wasWord
+	case 'M':
+		t.level = noMagic
+		t.word = t.wasWord
+	case 'V':
+		t.level = veryNoMagic
+		t.word = t.wasWord
+	case 'c', 'C':
+		t.caseFlag = r
+		t.word = t.wasWord
+	case 'z':
+		return fmt.Errorf("\\z%s Is Not Supported", string(t.next()))
+	case '_':
+		return t.withLineBreak()
+	default:
+		class, ok := classes[r]
+		if !ok {
+			return fmt.Errorf("Unknown Escape \\%c", r)
+		}
+		t.builder.WriteString(class)
+		t.hasAtom = true
+		t.word = strings.ContainsRune(wordClasses, r)
+	}
+	return nil
+}
+
+// withLineBreak is "\_" followed by a class, "." or a collection, which
+// then also matches a line break, or by "^" or "$", which then match at
+// the start or end of any line.
+func (t *translator) withLineBreak() error {
+	next := t.next()
+	if next == nil {
+		return errors.New("Missing Item After \\_")
+	}
+	switch r := next[0]; {
+	case r == '.':
+		t.builder.WriteString(`(?s:.)`)
+		t.hasAtom = true
+	case r == '^' || r == '$':
+		t.builder.WriteRune(r)
+	case r == '[':
+		before := t.builder.Len()
+		t.class()
+		translated := t.builder.String()
+		if t.builder.Len() == before || !strings.HasSuffix(translated, "]") {
+			return errors.New("Missing ] After \\_[")
+		}
+		t.builder.Reset()
+		t.builder.WriteString(translated[:before] + addLineBreak(translated[before:]))
+	default:
+		class, ok := classes[r]
+		if !ok || !strings.HasPrefix(class, "[") {
+			return fmt.Errorf("Unknown Escape \\_%c", r)
+		}
+		t.builder.WriteString(addLineBreak(class))
+		t.hasAtom = true
+	}
+	return nil
+}
+
+// addLineBreak makes a collection also match a line break.
+func addLineBreak(class string) string {
+	if strings.HasPrefix(class, "[^") {
+		return strings.TrimSuffix(class, `\n]`) + "]"
+	}
+	return class[:len(class)-1] + `\n]`
+}
+
+func (t *translator) next() []rune {
+	if t.index >= len(t.runes) {
+		return nil
+	}
+	t.index++
+	return t.runes[t.index-1 : t.index]
+}
+
+func (t *translator) special(r rune) error {
+	switch r {
+	case '^':
+		if t.hasAtom {
+			t.literal(r)
+			return nil
+		}
+		t.builder.WriteString("^")
+	case '$':
+		if t.index < len(t.runes) && !t.atBranchEnd() {
+			t.literal(r)
+			return nil
+		}
+		t.builder.WriteString("$")
+	case '.':
+		t.builder.WriteString(".")
+		t.hasAtom = true
+	case '[':
+		t.class()
+	case '~':
+		return noSubstitute
+	case '(':
+		t.builder.WriteString("(")
+		t.depth++
+		t.hasAtom = false
+	case '%':
+		if t.index < len(t.runes) && t.runes[t.index] == '(' {
+			t.index++
+			t.builder.WriteString("(?:")
+			t.depth++
+			t.hasAtom = false
+			return nil
+		}
+		return fmt.Errorf("\\%%%s Is Not Supported", string(t.next()))
+	case ')':
+		if t.depth == 0 {
+			return unmatchedClose
+		}
+		t.builder.WriteString(")")
+		t.depth--
+		t.hasAtom = true
+	case '|':
+		t.builder.WriteString("|")
+		t.hasAtom = false
+	case '&':
+		return errors.New("\\& Is Not Supported")
+	case '<':
+		if !t.wordFollows() {
+			return wordStart
+		}
+		t.builder.WriteString(`\b`)
+	case '>':
+		if !t.wasWord {
+			return wordEnd
+		}
+		t.builder.WriteString(`\b`)
+	case '@':
+		return unsupportedMulti
+	case '*', '+', '?', '=':
+		if !t.hasAtom {
+			t.literal(r)
+			return nil
+		}
+		multi := map[rune]string{'*': "*", '+': "+", '?': "?", '=': "?"}[r]
+		t.builder.WriteString(multi)
+		t.hasAtom = false
+		t.word = r == '+' && t.wasWord
+	case '{':
+		return t.braces()
+	}
+	return nil
+}
+
+// wordFollows reports whether the pattern continues with a character or a
+// class which only matches word characters, and which is not made optional
+// by a multi. Only then does "\b" match the start of a word, since there
+// is no lookahead to check the character after it.
+func (t *translator) wordFollows() bool {
+	rest := t.runes[t.index:]
+	switch {
+	case len(rest) > 0 && isWordRune(rest[0]):
+		rest = rest[1:]
+	case len(rest) > 1 && rest[0] == '\\' && strings.ContainsRune(wordClasses, rest[1]):
+		rest = rest[2:]
+	default:
+		return false
+	}
+	escaped := len(rest) > 1 && rest[0] == '\\'
+	if escaped {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return true
+	}
+	level, hasLevel := specialLevel[rest[0]]
+	if !hasLevel || (t.level >= level) == escaped {
+		return true
+	}
+	switch rest[0] {
+	case '*', '?', '=':
+		return false
+	case '{':
+		inside := strings.TrimPrefix(string(rest[1:]), "-")
+		digits := strings.IndexFunc(inside, func(r rune) bool { return r < '0' || r > '9' })
+		if digits < 0 {
+			digits = len(inside)
+		}
+		minimum, _ := strconv.Atoi(inside[:digits])
+		return minimum > 0
+	}
+	return true
+}
+
+// atBranchEnd reports whether the pattern continues with the end of a
+// group or another branch, where "$" still means the end of the line.
+func (t *translator) atBranchEnd() bool {
+	rest := string(t.runes[t.index:])
+	if t.level == veryMagic {
+		return strings.HasPrefix(rest, ")") || strings.HasPrefix(rest, "|")
+	}
+	return strings.HasPrefix(rest, `\)`) || strings.HasPrefix(rest, `\|`)
+}
+
+// class copies a collection such as "[a-z]" up to its closing bracket. A
+// bracket which is never closed is literal, as in vim.
+func (t *translator) class() {
+	start := t.index
+	end := start
+	if end < len(t.runes) && t.runes[end] == '^' {
+		end++
+	}
+	if end < len(t.runes) && t.runes[end] == ']' {
+		end++
+	}
+	for end < len(t.runes) && t.runes[end] != ']' {
+		if t.runes[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(t.runes) {
+		t.literal('[')
+		return
+	}
+	var builder strings.Builder
+	builder.WriteString("[")
+	for i := start; i < end; i++ {
+		r := t.runes[i]
+		switch {
+		case r == '\\' && i+1 < end:
+			i++
+			if t.runes[i] == 'e' {
+				builder.WriteString(`\x1b`)
+			} else {
+				builder.WriteRune('\\')
+				builder.WriteRune(t.runes[i])
+			}
+		case r == '[' && !strings.HasPrefix(string(t.runes[i:end]), "[:"):
+			builder.WriteString(`\[`)
+		case r == ']':
+			builder.WriteString(`\]`)
+		default:
+			if unicode.IsUpper(r) {
+				t.hasUpper = true
+			}
+			builder.WriteRune(r)
+		}
+	}
+	if t.runes[start] == '^' {
+		builder.WriteString(`\n`)
+	}
+	builder.WriteString("]")
+	t.builder.WriteString(builder.String())
+	t.index = end + 1
+	t.hasAtom = true
+}
+
+// braces is "\{n,m}" which repeats the atom before it n to m times, where
+// either number may be left out, and a "-" at the start matches as few
+// times as possible. The closing brace may also have a backslash.
+func (t *translator) braces() error {
+	end := t.index
+	for end < len(t.runes) && t.runes[end] != '}' {
+		end++
+	}
+	if end >= len(t.runes) {
+		return badBraces
+	}
+	inside := string(t.runes[t.index:end])
+	inside = strings.TrimSuffix(inside, `\`)
+	t.index = end + 1
+	lazy := strings.HasPrefix(inside, "-")
+	inside = strings.TrimPrefix(inside, "-")
+	for _, r := range inside {
+		if r != ',' && (r < '0' || r > '9') {
+			return badBraces
+		}
+	}
+	if strings.Count(inside, ",") > 1 {
+		return badBraces
+	}
+	if !t.hasAtom {
+		return badBraces
+	}
+	var multi string
+	switch {
+	case inside == "" || inside == ",":
+		multi = "*"
+	case strings.HasPrefix(inside, ","):
+		multi = "{0" + inside + "}"
+	default:
+		multi = "{" + inside + "}"
+	}
+	if lazy {
+		multi += "?"
+	}
+	t.builder.WriteString(multi)
+	t.hasAtom = false
+	if minimum, _ := strconv.Atoi(strings.SplitN(inside, ",", 2)[0]); minimum > 0 {
+		t.word = t.wasWord
+	}
+	return nil
+}
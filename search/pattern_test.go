@@ -0,0 +1,124 @@
+package search
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		options  Options
+		expected string
+	}{
+		{"abc", Options{}, "abc"},
+		{"a+(b)|c", Options{}, `a\+\(b\)\|c`},
+		{`a\+\(b\)\|c`, Options{}, "a+(b)|c"},
+		{`\va+(b)|c`, Options{}, "a+(b)|c"},
+		{`^a.*b$`, Options{}, "^a.*b$"},
+		{`a^b$c`, Options{}, `a\^b\$c`},
+		{`\Va.*b`, Options{}, `a\.\*b`},
+		{`\Va\.b`, Options{}, "a.b"},
+		{`\Ma.b\*`, Options{}, `a\.b*`},
+		{`\<word\>`, Options{}, `\bword\b`},
+		{`\v<word>`, Options{}, `\bword\b`},
+		{`\<\w\+\>`, Options{}, `\b[0-9A-Za-z_]+\b`},
+		{`\<a\{2,}x\c\>`, Options{}, `(?i)\ba{2,}x\b`},
+		{`a\{2,3}`, Options{}, "a{2,3}"},
+		{`a\{2,3\}`, Options{}, "a{2,3}"},
+		{`a\{,3}`, Options{}, "a{0,3}"},
+		{`a\{}`, Options{}, "a*"},
+		{`a\{-1,}`, Options{}, "a{1,}?"},
+		{`\d\s\w`, Options{}, `[0-9][ \t][0-9A-Za-z_]`},
+		{`[a-z]\+`, Options{}, "[a-z]+"},
+		{`[]x]`, Options{}, `[\]x]`},
+		{`[abc`, Options{}, `\[abc`},
+		{`\%(ab\)`, Options{}, "(?:ab)"},
+		{"*a", Options{}, `\*a`},
+		{`[^a]`, Options{}, `[^a\n]`},
+		{`a\nb`, Options{}, `a\nb`},
+		{`\_s`, Options{}, `[ \t\n]`},
+		{`\_S`, Options{}, `[^ \t]`},
+		{`\_.`, Options{}, `(?s:.)`},
+		{`\_[^a]`, Options{}, `[^a]`},
+		{`\_[ab]`, Options{}, `[ab\n]`},
+		{"abc", Options{IgnoreCase: true}, "(?i)abc"},
+		{"Abc", Options{IgnoreCase: true, SmartCase: true}, "Abc"},
+		{`\Sbc`, Options{IgnoreCase: true, SmartCase: true}, `(?i)[^ \t\n]bc`},
+		{`abc\c`, Options{}, "(?i)abc"},
+		{`\Cabc`, Options{IgnoreCase: true}, "abc"},
+	}
+	for _, test := range tests {
+		translated, err := Translate(test.pattern, test.options)
+		if err != nil {
+			t.Errorf("%q: %v", test.pattern, err)
+			continue
+		}
+		if translated != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.pattern, test.expected, translated)
+		}
+	}
+}
+
+func TestTranslateMatches(t *testing.T) {
+	translated, err := Translate(`\<in\>`, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(translated)
+	if re.MatchString("inside") || !re.MatchString("go in there") {
+		t.Error("bad word boundaries")
+	}
+	translated, err = Translate(`\<in`, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re = regexp.MustCompile(translated)
+	if re.MatchString("skin") || !re.MatchString("skin inside") {
+		t.Error("bad start of word")
+	}
+	translated, err = Translate(`\V(a+b)`, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(translated).MatchString("x(a+b)y") {
+		t.Error("bad literal pattern")
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{`\(a`, `Unmatched \(`},
+		{`a\)`, `Unmatched \)`},
+		{`\va)`, `Unmatched \)`},
+		{`a\{x}`, `Invalid Count In \{`},
+		{`a\{2`, `Invalid Count In \{`},
+		{`\{2}`, `Invalid Count In \{`},
+		{`a\{3,1}`, "Invalid Repeat Count: {3,1}"},
+		{`a\{2000}`, "Invalid Repeat Count: {2000}"},
+		{`\q`, `Unknown Escape \q`},
+		{`\zsa`, `\zs Is Not Supported`},
+		{`a\@=`, `Lookaround With \@ Is Not Supported`},
+		{"~", "No Previous Substitute Regular Expression"},
+		{`\>foo`, `\> Must Follow A Word Character`},
+		{`foo \>`, `\> Must Follow A Word Character`},
+		{`a*\>`, `\> Must Follow A Word Character`},
+		{`foo\<`, `\< Must Be Followed By A Word Character`},
+		{`\< foo`, `\< Must Be Followed By A Word Character`},
+		{`\<a*`, `\< Must Be Followed By A Word Character`},
+		{`\v<a{0,2}`, `\< Must Be Followed By A Word Character`},
+	}
+	for _, test := range tests {
+		_, err := Translate(test.pattern, Options{})
+		if err == nil {
+			t.Errorf("%q: expected an error", test.pattern)
+			continue
+		}
+		if err.Error() != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.pattern, test.expected, err.Error())
+		}
+	}
+}
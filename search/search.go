@@ -3,6 +3,9 @@ package search
 import (
 	"fmt"
 	"regexp"
+	"regexp/syntax"
+	"sort"
+	"unicode/utf8"
 
 	"github.com/bkthomps/Ven/buffer"
 )
@@ -21,9 +24,12 @@ func AllMatches(pattern string, start *buffer.Line, maxLineCount int) (matches [
 	count := 0
 	firstLineIndex = 0
 	matches = make([]MatchLine, 0)
-	re, err := regexp.Compile(pattern)
+	re, multiline, err := compile(pattern)
 	if err != nil {
-		return matches, 0, fmt.Errorf("invalid regex: %w", err)
+		return matches, 0, err
+	}
+	if multiline {
+		return join(start, -1).allMatches(re, maxLineCount)
 	}
 	for traverse := start; traverse != nil; traverse = traverse.Next {
 		if count > 0 {
@@ -35,27 +41,16 @@ func AllMatches(pattern string, start *buffer.Line, maxLineCount int) (matches [
 		if count == 0 {
 			firstLineIndex++
 		}
-		strData := string(traverse.Data)
-		indices := re.FindAllStringIndex(strData, -1)
-		if len(indices) == 0 {
+		inLine := lineMatches(re, traverse)
+		if len(inLine) == 0 {
 			continue
 		}
 		if count == 0 {
 			count++
 		}
-		byteToRuneIndex := make(map[int]int, 0)
-		runeIndex := 0
-		for byteIndex := range strData + " " {
-			byteToRuneIndex[byteIndex] = runeIndex
-			runeIndex++
-		}
-		matchInstances := make([]MatchInstance, 0)
-		for _, pair := range indices {
-			instance := MatchInstance{
-				StartOffset: byteToRuneIndex[pair[0]],
-				Length:      byteToRuneIndex[pair[1]] - byteToRuneIndex[pair[0]],
-			}
-			matchInstances = append(matchInstances, instance)
+		matchInstances := make([]MatchInstance, 0, len(inLine))
+		for _, found := range inLine {
+			matchInstances = append(matchInstances, MatchInstance{StartOffset: found.Offset, Length: found.Length})
 		}
 		match := MatchLine{
 			Line:      traverse,
@@ -65,3 +60,291 @@ func AllMatches(pattern string, start *buffer.Line, maxLineCount int) (matches [
 	}
 	return matches, firstLineIndex, nil
 }
+
+// Match is where a pattern matched, starting at a rune offset into the
+// line. A match which spans several lines ends on a later line, and its
+// length counts each line break as one rune. The end is just after the
+// last rune of the match, where the line break of a line is at the offset
+// of its length.
+type Match struct {
+	Line   *buffer.Line
+	Offset int
+	Length int
+	End    buffer.Position
+}
+
+// Find returns the first match after the position, or the last match
+// before it when backward, wrapping around the ends of the file.
+func Find(pattern string, from buffer.Position, backward bool) (match Match, found bool, err error) {
+	re, multiline, err := compile(pattern)
+	if err != nil {
+		return Match{}, false, err
+	}
+	first, last := from.Line, from.Line
+	for first.Prev != nil {
+		first = first.Prev
+	}
+	if multiline {
+		match, found = join(first, -1).find(re, from, backward)
+		return match, found, nil
+	}
+	for last.Next != nil {
+		last = last.Next
+	}
+	if backward {
+		if match, found = lastMatch(re, from.Line, from.Offset); found {
+			return match, true, nil
+		}
+		for traverse := from.Line.Prev; traverse != nil; traverse = traverse.Prev {
+			if match, found = lastMatch(re, traverse, len(traverse.Data)+1); found {
+				return match, true, nil
+			}
+		}
+		for traverse := last; traverse != from.Line.Prev; traverse = traverse.Prev {
+			if match, found = lastMatch(re, traverse, len(traverse.Data)+1); found {
+				return match, true, nil
+			}
+		}
+		return Match{}, false, nil
+	}
+	if match, found = firstMatch(re, from.Line, from.Offset+1); found {
+		return match, true, nil
+	}
+	for traverse := from.Line.Next; traverse != nil; traverse = traverse.Next {
+		if match, found = firstMatch(re, traverse, 0); found {
+			return match, true, nil
+		}
+	}
+	for traverse := first; traverse != from.Line.Next; traverse = traverse.Next {
+		if match, found = firstMatch(re, traverse, 0); found {
+			return match, true, nil
+		}
+	}
+	return Match{}, false, nil
+}
+
+// lineMatches returns the matches in the line as rune offsets.
+func lineMatches(re *regexp.Regexp, line *buffer.Line) []Match {
+	data := string(line.Data)
+	indices := re.FindAllStringIndex(data, -1)
+	if len(indices) == 0 {
+		return nil
+	}
+	byteToRuneIndex := make(map[int]int, len(data)+1)
+	runeIndex := 0
+	for byteIndex := range data + " " {
+		byteToRuneIndex[byteIndex] = runeIndex
+		runeIndex++
+	}
+	matches := make([]Match, 0, len(indices))
+	for _, pair := range indices {
+		start := byteToRuneIndex[pair[0]]
+		length := byteToRuneIndex[pair[1]] - start
+		end := buffer.Position{Line: line, Offset: start + length}
+		matches = append(matches, Match{Line: line, Offset: start, Length: length, End: end})
+	}
+	return matches
+}
+
+// firstMatch is the first match in the line starting at or after the
+// offset.
+func firstMatch(re *regexp.Regexp, line *buffer.Line, offset int) (Match, bool) {
+	for _, match := range lineMatches(re, line) {
+		if match.Offset >= offset {
+			return match, true
+		}
+	}
+	return Match{}, false
+}
+
+// lastMatch is the last match in the line starting before the offset.
+func lastMatch(re *regexp.Regexp, line *buffer.Line, offset int) (Match, bool) {
+	matches := lineMatches(re, line)
+	for i := len(matches) - 1; i >= 0; i-- {
+		if matches[i].Offset < offset {
+			return matches[i], true
+		}
+	}
+	return Match{}, false
+}
+
+// compile compiles the pattern, and reports whether it can match a line
+// break, in which case ^ and $ match at the start and end of each line.
+func compile(pattern string) (re *regexp.Regexp, multiline bool, err error) {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid regex: %w", err)
+	}
+	multiline = matchesLineBreak(parsed)
+	if multiline {
+		pattern = "(?m)" + pattern
+	}
+	re, err = regexp.Compile(pattern)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid regex: %w", err)
+	}
+	return re, multiline, nil
+}
+
+func matchesLineBreak(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpAnyChar:
+		return true
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '\n' {
+				return true
+			}
+		}
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\n' && '\n' <= re.Rune[i+1] {
+				return true
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		if matchesLineBreak(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// text is lines joined into one string, each followed by a line break, so
+// that a pattern can match across them. The lines are read in order from
+// the first one, and starts holds the byte index where each line starts.
+type text struct {
+	lines  []*buffer.Line
+	starts []int
+	data   string
+}
+
+// join reads count lines from the first one into a text, or every line to
+// the end of the file when count is negative.
+func join(first *buffer.Line, count int) text {
+	var joined text
+	var data []byte
+	for traverse := first; traverse != nil && count != 0; traverse = traverse.Next {
+		joined.lines = append(joined.lines, traverse)
+		joined.starts = append(joined.starts, len(data))
+		data = append(data, string(traverse.Data)...)
+		data = append(data, '\n')
+		count--
+	}
+	joined.data = string(data)
+	return joined
+}
+
+// lineIndex is the index of the line which holds the byte index.
+func (joined text) lineIndex(byteIndex int) int {
+	return sort.Search(len(joined.starts), func(i int) bool {
+		return joined.starts[i] > byteIndex
+	}) - 1
+}
+
+// offset is the rune offset of the byte index into its line.
+func (joined text) offset(line, byteIndex int) int {
+	return utf8.RuneCountInString(joined.data[joined.starts[line]:byteIndex])
+}
+
+// byteIndex is the byte index of a position in one of the lines.
+func (joined text) byteIndex(position buffer.Position) int {
+	for i, line := range joined.lines {
+		if line == position.Line {
+			index := joined.starts[i]
+			for offset := 0; offset < position.Offset && offset < len(line.Data); offset++ {
+				index += utf8.RuneLen(line.Data[offset])
+			}
+			return index
+		}
+	}
+	return 0
+}
+
+func (joined text) match(pair []int) Match {
+	start := joined.lineIndex(pair[0])
+	match := Match{
+		Line:   joined.lines[start],
+		Offset: joined.offset(start, pair[0]),
+		Length: utf8.RuneCountInString(joined.data[pair[0]:pair[1]]),
+	}
+	match.End = buffer.Position{Line: match.Line, Offset: match.Offset}
+	if pair[1] > pair[0] {
+		end := joined.lineIndex(pair[1] - 1)
+		match.End = buffer.Position{Line: joined.lines[end], Offset: joined.offset(end, pair[1]-1) + 1}
+	}
+	return match
+}
+
+// find is Find over the text, which holds the whole file.
+func (joined text) find(re *regexp.Regexp, from buffer.Position, backward bool) (Match, bool) {
+	indices := re.FindAllStringIndex(joined.data, -1)
+	if len(indices) == 0 {
+		return Match{}, false
+	}
+	at := joined.byteIndex(from)
+	if backward {
+		for i := len(indices) - 1; i >= 0; i-- {
+			if indices[i][0] < at {
+				return joined.match(indices[i]), true
+			}
+		}
+		return joined.match(indices[len(indices)-1]), true
+	}
+	for _, pair := range indices {
+		if pair[0] > at {
+			return joined.match(pair), true
+		}
+	}
+	return joined.match(indices[0]), true
+}
+
+// allMatches is AllMatches over the text. A match which spans several
+// lines has an instance on each of them, where an instance which covers a
+// line break reaches past the end of its line.
+func (joined text) allMatches(re *regexp.Regexp, maxLineCount int) (matches []MatchLine, firstLineIndex int, err error) {
+	instances := make([][]MatchInstance, len(joined.lines))
+	for _, pair := range re.FindAllStringIndex(joined.data, -1) {
+		for line := joined.lineIndex(pair[0]); line < len(joined.lines); line++ {
+			lineStart := joined.starts[line]
+			lineEnd := len(joined.data)
+			if line+1 < len(joined.starts) {
+				lineEnd = joined.starts[line+1]
+			}
+			if lineStart >= pair[1] && pair[1] > pair[0] {
+				break
+			}
+			start, end := pair[0], pair[1]
+			if start < lineStart {
+				start = lineStart
+			}
+			if end > lineEnd {
+				end = lineEnd
+			}
+			offset := joined.offset(line, start)
+			instances[line] = append(instances[line], MatchInstance{StartOffset: offset, Length: joined.offset(line, end) - offset})
+			if pair[1] <= lineEnd {
+				break
+			}
+		}
+	}
+	matches = make([]MatchLine, 0)
+	first := -1
+	for line := range joined.lines {
+		if len(instances[line]) == 0 {
+			continue
+		}
+		if first == -1 {
+			first = line
+		}
+		if line-first >= maxLineCount {
+			break
+		}
+		matches = append(matches, MatchLine{Line: joined.lines[line], Instances: instances[line]})
+	}
+	if first == -1 {
+		return matches, len(joined.lines), nil
+	}
+	return matches, first + 1, nil
+}
@@ -329,3 +329,96 @@ func TestMalformedRegex(t *testing.T) {
 		t.Error("expected an error")
 	}
 }
+
+func linkedLines(texts ...string) []*buffer.Line {
+	lines := make([]*buffer.Line, len(texts))
+	for i, text := range texts {
+		lines[i] = &buffer.Line{Data: []rune(text)}
+		if i > 0 {
+			lines[i].Prev = lines[i-1]
+			lines[i-1].Next = lines[i]
+		}
+	}
+	return lines
+}
+
+func TestFindForward(t *testing.T) {
+	lines := linkedLines("ab ab", "cd", "ab")
+	match, found, err := Find("ab", buffer.Position{Line: lines[0], Offset: 0}, false)
+	if err != nil || !found || match.Line != lines[0] || match.Offset != 3 || match.Length != 2 {
+		t.Errorf("bad match in the same line: %+v", match)
+	}
+	match, found, _ = Find("ab", buffer.Position{Line: lines[0], Offset: 3}, false)
+	if !found || match.Line != lines[2] || match.Offset != 0 {
+		t.Errorf("bad match in a later line: %+v", match)
+	}
+	match, found, _ = Find("ab", buffer.Position{Line: lines[2], Offset: 0}, false)
+	if !found || match.Line != lines[0] || match.Offset != 0 {
+		t.Errorf("search should wrap around: %+v", match)
+	}
+	if _, found, _ = Find("xy", buffer.Position{Line: lines[1]}, false); found {
+		t.Error("expected no match")
+	}
+}
+
+func TestFindBackward(t *testing.T) {
+	lines := linkedLines("ab ab", "cd", "汉ab")
+	match, found, _ := Find("ab", buffer.Position{Line: lines[0], Offset: 3}, true)
+	if !found || match.Line != lines[0] || match.Offset != 0 {
+		t.Errorf("bad match in the same line: %+v", match)
+	}
+	match, found, _ = Find("ab", buffer.Position{Line: lines[0], Offset: 0}, true)
+	if !found || match.Line != lines[2] || match.Offset != 1 {
+		t.Errorf("search should wrap around: %+v", match)
+	}
+	if _, _, err := Find("a(", buffer.Position{Line: lines[0]}, true); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestFindAcrossLines(t *testing.T) {
+	lines := linkedLines("ab", "汉cd", "ab", "cd")
+	match, found, err := Find(`b\n.c`, buffer.Position{Line: lines[0], Offset: 0}, false)
+	if err != nil || !found || match.Line != lines[0] || match.Offset != 1 || match.Length != 4 {
+		t.Errorf("bad match across lines: %+v", match)
+	}
+	if match.End.Line != lines[1] || match.End.Offset != 2 {
+		t.Errorf("bad end of match: %+v", match.End)
+	}
+	match, found, _ = Find(`b\nc`, buffer.Position{Line: lines[0], Offset: 0}, false)
+	if !found || match.Line != lines[2] || match.Offset != 1 {
+		t.Errorf("bad match in a later line: %+v", match)
+	}
+	match, found, _ = Find(`b\nc`, buffer.Position{Line: lines[2], Offset: 1}, true)
+	if !found || match.Line != lines[2] || match.Offset != 1 {
+		t.Errorf("search should wrap around: %+v", match)
+	}
+	match, found, _ = Find(`^c[a-z]*\n`, buffer.Position{Line: lines[0], Offset: 0}, false)
+	if !found || match.Line != lines[3] || match.End.Line != lines[3] || match.End.Offset != 3 {
+		t.Errorf("bad match of the last line break: %+v", match)
+	}
+}
+
+func TestAllMatchesAcrossLines(t *testing.T) {
+	lines := linkedLines("xab", "", "cd", "ab", "cd")
+	matches, firstLineIndex, err := AllMatches(`ab\n\nc`, lines[0], 3)
+	if err != nil || firstLineIndex != 1 {
+		t.Fatalf("bad first line: %d, %v", firstLineIndex, err)
+	}
+	expected := []struct {
+		line     *buffer.Line
+		instance MatchInstance
+	}{
+		{lines[0], MatchInstance{StartOffset: 1, Length: 3}},
+		{lines[1], MatchInstance{StartOffset: 0, Length: 1}},
+		{lines[2], MatchInstance{StartOffset: 0, Length: 1}},
+	}
+	if len(matches) != len(expected) {
+		t.Fatalf("bad match count: %d", len(matches))
+	}
+	for i, match := range matches {
+		if match.Line != expected[i].line || len(match.Instances) != 1 || match.Instances[0] != expected[i].instance {
+			t.Errorf("bad match on line %d: %+v", i, match.Instances)
+		}
+	}
+}